@@ -31,6 +31,9 @@ func Execute() {
 func init() {
 	rootCmd.AddCommand(compileCmd)
 	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("tls-cert", "", "TLS certificate file; serves HTTPS and HTTP/2 over TLS when set")
+	serveCmd.Flags().String("tls-key", "", "TLS private key file")
 }
 
 var compileCmd = &cobra.Command{
@@ -76,6 +79,16 @@ var serveCmd = &cobra.Command{
 		}
 
 		// Start server
+		certFile, _ := cmd.Flags().GetString("tls-cert")
+		keyFile, _ := cmd.Flags().GetString("tls-key")
+		if certFile != "" || keyFile != "" {
+			log.Println("Starting Kaimon API Gateway on :8080 (TLS)")
+			if err := fw.StartTLS(":8080", certFile, keyFile); err != nil {
+				log.Fatalf("Failed to start server: %v", err)
+			}
+			return
+		}
+
 		log.Println("Starting Kaimon API Gateway on :8080")
 		if err := fw.Start(":8080"); err != nil {
 			log.Fatalf("Failed to start server: %v", err)
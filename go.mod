@@ -7,6 +7,7 @@ toolchain go1.24.11
 require (
 	github.com/labstack/echo/v4 v4.14.0
 	github.com/spf13/cobra v1.10.2
+	google.golang.org/protobuf v1.36.11
 )
 
 require (
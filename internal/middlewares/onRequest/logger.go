@@ -28,7 +28,11 @@ func (m *LoggerMiddleware) Name() string {
 func (m *LoggerMiddleware) Handle(next framework.HandlerFunc) framework.HandlerFunc {
 	return func(ctx framework.Context) error {
 		req := ctx.Request()
-		log.Printf("[%s] %s %s", req.Method, req.URL.Path, req.RemoteAddr)
+		if variant, _ := ctx.Get(middleware.ContextKeyVariant).(string); variant != "" {
+			log.Printf("[%s] %s %s variant=%s", req.Method, req.URL.Path, req.RemoteAddr, variant)
+		} else {
+			log.Printf("[%s] %s %s", req.Method, req.URL.Path, req.RemoteAddr)
+		}
 		return next(ctx)
 	}
 }
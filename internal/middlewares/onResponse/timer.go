@@ -33,7 +33,15 @@ func (m *TimerMiddleware) Handle(next framework.HandlerFunc) framework.HandlerFu
 		duration := time.Since(start)
 
 		req := ctx.Request()
-		log.Printf("[TIMER] %s %s took %v", req.Method, req.URL.Path, duration)
+		tag := ""
+		if variant, _ := ctx.Get(middleware.ContextKeyVariant).(string); variant != "" {
+			tag = " variant=" + variant
+		}
+		if streamed, _ := ctx.Get(middleware.ContextKeyStream).(bool); streamed {
+			log.Printf("[TIMER] %s %s streamed for %v%s", req.Method, req.URL.Path, duration, tag)
+		} else {
+			log.Printf("[TIMER] %s %s took %v%s", req.Method, req.URL.Path, duration, tag)
+		}
 
 		return err
 	}
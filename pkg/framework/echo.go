@@ -4,8 +4,10 @@ package framework
 // For adding features, work in internal/ directory instead.
 
 import (
+	"bufio"
 	"context"
 	"io"
+	"net"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
@@ -18,8 +20,17 @@ type EchoFramework struct {
 
 // NewEchoFramework creates a new Echo framework instance
 func NewEchoFramework() Framework {
+	e := echo.New()
+
+	// Accept HTTP/2 without TLS (h2c with prior knowledge) next to HTTP/1.1, as gRPC clients use
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	e.Server.Protocols = protocols
+
 	return &EchoFramework{
-		e: echo.New(),
+		e: e,
 	}
 }
 
@@ -35,6 +46,16 @@ func (ef *EchoFramework) Start(address string) error {
 	return ef.e.Start(address)
 }
 
+// StartTLS starts the server with TLS, negotiating HTTP/2 or HTTP/1.1
+func (ef *EchoFramework) StartTLS(address, certFile, keyFile string) error {
+	return ef.e.StartTLS(address, certFile, keyFile)
+}
+
+// ServeHTTP serves a request through Echo, so the framework can run behind any http.Server
+func (ef *EchoFramework) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ef.e.ServeHTTP(w, r)
+}
+
 // Shutdown gracefully shuts down the server
 func (ef *EchoFramework) Shutdown() error {
 	return ef.e.Shutdown(context.Background())
@@ -70,6 +91,11 @@ func (er *EchoRouter) PATCH(path string, handler HandlerFunc) {
 	er.group.PATCH(path, er.wrapHandler(handler))
 }
 
+// Handle registers a route for any HTTP method
+func (er *EchoRouter) Handle(method, path string, handler HandlerFunc) {
+	er.group.Add(method, path, er.wrapHandler(handler))
+}
+
 // Group creates a route group
 func (er *EchoRouter) Group(prefix string) Router {
 	return &EchoRouter{
@@ -116,11 +142,30 @@ func (ec *EchoContext) Request() *http.Request {
 	return ec.c.Request()
 }
 
+// SetRequest replaces the HTTP request
+func (ec *EchoContext) SetRequest(r *http.Request) {
+	ec.c.SetRequest(r)
+}
+
 // Response returns the HTTP response writer
 func (ec *EchoContext) Response() http.ResponseWriter {
 	return ec.c.Response()
 }
 
+// SetResponse replaces the HTTP response writer, e.g. with one that captures the body
+func (ec *EchoContext) SetResponse(w http.ResponseWriter) {
+	if res, ok := w.(*echo.Response); ok {
+		ec.c.SetResponse(res)
+		return
+	}
+	ec.c.SetResponse(echo.NewResponse(w, ec.c.Echo()))
+}
+
+// Path returns the registered path of the matched route
+func (ec *EchoContext) Path() string {
+	return ec.c.Path()
+}
+
 // Param returns the URL parameter
 func (ec *EchoContext) Param(key string) string {
 	return ec.c.Param(key)
@@ -155,3 +200,8 @@ func (ec *EchoContext) Set(key string, value interface{}) {
 func (ec *EchoContext) Get(key string) interface{} {
 	return ec.c.Get(key)
 }
+
+// Hijack takes over the client connection, e.g. for protocol upgrades
+func (ec *EchoContext) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(ec.c.Response()).Hijack()
+}
@@ -3,12 +3,19 @@ package framework
 // WARNING: This is a core package. Do NOT modify unless you're changing the framework abstraction.
 // For adding features, work in internal/ directory instead.
 
-import "net/http"
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
 
 // Context represents an HTTP request context
 type Context interface {
 	Request() *http.Request
+	SetRequest(r *http.Request)
 	Response() http.ResponseWriter
+	SetResponse(w http.ResponseWriter)
+	Path() string
 	Param(key string) string
 	QueryParam(key string) string
 	Body() ([]byte, error)
@@ -16,6 +23,7 @@ type Context interface {
 	String(code int, data string) error
 	Set(key string, value interface{})
 	Get(key string) interface{}
+	Hijack() (net.Conn, *bufio.ReadWriter, error)
 }
 
 // HandlerFunc represents a handler function
@@ -31,6 +39,7 @@ type Router interface {
 	PUT(path string, handler HandlerFunc)
 	DELETE(path string, handler HandlerFunc)
 	PATCH(path string, handler HandlerFunc)
+	Handle(method, path string, handler HandlerFunc)
 	Group(prefix string) Router
 	Use(middleware ...MiddlewareFunc)
 }
@@ -39,5 +48,6 @@ type Router interface {
 type Framework interface {
 	Router() Router
 	Start(address string) error
+	StartTLS(address, certFile, keyFile string) error
 	Shutdown() error
 }
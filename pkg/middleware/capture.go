@@ -0,0 +1,133 @@
+package middleware
+
+// WARNING: This is a core package. Do NOT modify unless you're changing response capturing.
+// For rewriting responses, call Capture from a middleware in internal/middlewares/onResponse instead.
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// ErrBodyTooLarge is returned by writes that would grow a captured body past its limit
+var ErrBodyTooLarge = errors.New("captured response body too large")
+
+// ResponseCapture buffers a handler's response so an onResponse middleware can rewrite it
+type ResponseCapture struct {
+	original http.ResponseWriter
+	accept   func(status int, header http.Header) bool
+	limit    int64
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	bypassed bool
+	overflow bool
+}
+
+// Capture runs next with the response buffered instead of sent. The caller inspects or
+// rewrites the captured response and then sends it with Send.
+func Capture(ctx framework.Context, next framework.HandlerFunc) (*ResponseCapture, error) {
+	return CaptureWhen(ctx, next, nil, 0)
+}
+
+// CaptureWhen runs next like Capture, but asks accept whether to buffer the response once
+// its status and headers are known. Declined responses go straight to the client, flushes
+// included. A positive limit caps the buffered body; writes past it fail with ErrBodyTooLarge.
+func CaptureWhen(ctx framework.Context, next framework.HandlerFunc, accept func(status int, header http.Header) bool, limit int64) (*ResponseCapture, error) {
+	original := ctx.Response()
+
+	// Headers go straight to the original writer; they aren't sent before Send
+	capture := &ResponseCapture{original: original, accept: accept, limit: limit, header: original.Header()}
+	ctx.SetResponse(capture)
+	defer ctx.SetResponse(original)
+
+	err := next(ctx)
+	return capture, err
+}
+
+// Header returns the response headers
+func (c *ResponseCapture) Header() http.Header {
+	return c.header
+}
+
+// WriteHeader records the status code, or sends it on if the response isn't buffered
+func (c *ResponseCapture) WriteHeader(status int) {
+	if c.status != 0 {
+		return
+	}
+	c.status = status
+	if c.accept != nil && !c.accept(status, c.header) {
+		c.bypassed = true
+		c.original.WriteHeader(status)
+	}
+}
+
+// Write buffers body bytes, or sends them on if the response isn't buffered
+func (c *ResponseCapture) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.bypassed {
+		return c.original.Write(b)
+	}
+	if c.limit > 0 && int64(c.body.Len()+len(b)) > c.limit {
+		c.overflow = true
+		return 0, ErrBodyTooLarge
+	}
+	return c.body.Write(b)
+}
+
+// Flush sends buffered data to the client if the response isn't buffered, and is a no-op
+// otherwise; buffered bodies are sent as a whole by Send
+func (c *ResponseCapture) Flush() {
+	if c.bypassed {
+		_ = http.NewResponseController(c.original).Flush()
+	}
+}
+
+// Written reports whether the handler wrote a response
+func (c *ResponseCapture) Written() bool {
+	return c.status != 0
+}
+
+// Bypassed reports whether the response went straight to the client instead of being
+// buffered, in which case there is nothing to Send
+func (c *ResponseCapture) Bypassed() bool {
+	return c.bypassed
+}
+
+// Overflowed reports whether the body grew past the limit; nothing was sent to the client
+func (c *ResponseCapture) Overflowed() bool {
+	return c.overflow
+}
+
+// Status returns the captured status code
+func (c *ResponseCapture) Status() int {
+	if c.status == 0 {
+		return http.StatusOK
+	}
+	return c.status
+}
+
+// Body returns the captured body
+func (c *ResponseCapture) Body() []byte {
+	return c.body.Bytes()
+}
+
+// SetBody replaces the captured body
+func (c *ResponseCapture) SetBody(body []byte) {
+	c.body.Reset()
+	c.body.Write(body)
+}
+
+// Send writes the captured response to the client
+func (c *ResponseCapture) Send(ctx framework.Context) error {
+	res := ctx.Response()
+	res.Header().Set("Content-Length", strconv.Itoa(c.body.Len()))
+	res.WriteHeader(c.Status())
+	_, err := res.Write(c.body.Bytes())
+	return err
+}
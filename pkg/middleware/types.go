@@ -13,6 +13,15 @@ const (
 	PhaseOnResponse Phase = "onResponse"
 )
 
+// Context keys the gateway sets for middlewares to read
+const (
+	// ContextKeyStream is true when the response was streamed to the client
+	ContextKeyStream = "kaimon.stream"
+
+	// ContextKeyVariant is the name of the split variant serving the request
+	ContextKeyVariant = "kaimon.variant"
+)
+
 // Middleware represents a middleware with metadata
 type Middleware struct {
 	Name    string
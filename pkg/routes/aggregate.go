@@ -0,0 +1,284 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing response aggregation.
+// For aggregating backends, set "backends" on routes in config/routes/ instead.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// Backend failure policies
+const (
+	FailurePolicyFailAll = "failAll"
+	FailurePolicyPartial = "partial"
+)
+
+// maxBackendResponseBytes caps each backend response an aggregated route reads
+const maxBackendResponseBytes = 8 << 20
+
+// defaultAggregateMaxBodyBytes caps the request body copied to every backend when the route sets no limit
+const defaultAggregateMaxBodyBytes = 1 << 20
+
+// responsesKey holds the decoded responses of earlier steps of a sequence in the context
+const responsesKey = "kaimon.responses"
+
+// completedHeader tells clients whether every backend of an aggregated route answered
+const completedHeader = "X-Kaimon-Completed"
+
+// backendRoutes expands an aggregated route into one single-target route per backend,
+// so each backend is proxied like any other route
+func backendRoutes(route Route) []Route {
+	routes := make([]Route, 0, len(route.Backends))
+
+	for _, backend := range route.Backends {
+		sub := route
+		sub.Target = backend.Target
+		sub.Targets = nil
+		sub.Backends = nil
+		if backend.Method != "" {
+			sub.Method = strings.ToUpper(backend.Method)
+		}
+		if backend.Timeout != nil {
+			sub.Timeout = backend.Timeout
+		}
+
+		// Backend headers override route headers
+		sub.Headers = make(map[string]string, len(route.Headers)+len(backend.Headers))
+		for key, value := range route.Headers {
+			sub.Headers[key] = value
+		}
+		for key, value := range backend.Headers {
+			sub.Headers[key] = value
+		}
+
+		routes = append(routes, sub)
+	}
+
+	return routes
+}
+
+// aggregator calls several backends, in parallel or in sequence, and merges their JSON responses
+type aggregator struct {
+	route    Route
+	backends []*proxy
+	partial  bool
+}
+
+// backendResult is the decoded response of one backend
+type backendResult struct {
+	value interface{}
+	err   error
+}
+
+// handle fans the request out to every backend and answers with the merged JSON
+func (a *aggregator) handle(ctx framework.Context) error {
+	req := ctx.Request()
+
+	// Every backend gets its own copy of the request body
+	maxBodyBytes := a.route.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultAggregateMaxBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Response(), req.Body, maxBodyBytes))
+	if err != nil {
+		return a.backends[0].fail(ctx, err)
+	}
+
+	results := make([]backendResult, len(a.backends))
+	if a.route.Sequence {
+		a.callSequence(ctx, body, results)
+	} else {
+		// Backends run concurrently, so each gets a snapshot instead of the shared context
+		query := req.URL.Query()
+		var wg sync.WaitGroup
+		for i, backend := range a.backends {
+			snapshot := &contextSnapshot{Context: ctx, query: query}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = a.call(snapshot, backend, body)
+			}()
+		}
+		wg.Wait()
+	}
+
+	merged := make(map[string]interface{})
+	completed := true
+	for i, result := range results {
+		backend := a.route.Backends[i]
+		if result.err != nil {
+			log.Printf("Backend %s of %s %s failed: %v", backend.Target, req.Method, req.URL.Path, result.err)
+			completed = false
+			continue
+		}
+
+		if backend.Discard {
+			continue
+		}
+		if backend.Group != "" {
+			merged[backend.Group] = result.value
+			continue
+		}
+		object, ok := result.value.(map[string]interface{})
+		if !ok {
+			log.Printf("Backend %s of %s %s returned non-object JSON without a group", backend.Target, req.Method, req.URL.Path)
+			completed = false
+			continue
+		}
+		deepMerge(merged, object)
+	}
+
+	if !completed && (!a.partial || len(merged) == 0) {
+		return a.backends[0].fail(ctx, errProxyFailed)
+	}
+
+	ctx.Response().Header().Set(completedHeader, fmt.Sprintf("%t", completed))
+	return ctx.JSON(http.StatusOK, merged)
+}
+
+// callSequence calls the backends one after another, exposing each response to the
+// target templates of the following steps. A failed step stops the chain.
+func (a *aggregator) callSequence(ctx framework.Context, body []byte, results []backendResult) {
+	responses := make([]interface{}, 0, len(a.backends))
+
+	for i, backend := range a.backends {
+		ctx.Set(responsesKey, responses)
+		results[i] = a.call(ctx, backend, body)
+		if results[i].err != nil {
+			for j := i + 1; j < len(results); j++ {
+				results[j].err = fmt.Errorf("skipped after step %d failed", i)
+			}
+			return
+		}
+		responses = append(responses, results[i].value)
+	}
+}
+
+// contextSnapshot is a view of the request context that one goroutine of an aggregated
+// route can use while others do the same. The query is parsed up front, since the
+// framework may parse it lazily on first read, and values set by a backend stay local.
+type contextSnapshot struct {
+	framework.Context
+	query  url.Values
+	values map[string]interface{}
+}
+
+// QueryParam returns a query parameter from the pre-parsed query
+func (s *contextSnapshot) QueryParam(key string) string {
+	return s.query.Get(key)
+}
+
+// Set stores a value for this backend only
+func (s *contextSnapshot) Set(key string, value interface{}) {
+	if s.values == nil {
+		s.values = make(map[string]interface{})
+	}
+	s.values[key] = value
+}
+
+// Get returns a value set for this backend, or one from the shared context
+func (s *contextSnapshot) Get(key string) interface{} {
+	if value, ok := s.values[key]; ok {
+		return value
+	}
+	return s.Context.Get(key)
+}
+
+// call sends the request to one backend and decodes its JSON response
+func (a *aggregator) call(ctx framework.Context, backend *proxy, body []byte) backendResult {
+	req := ctx.Request().Clone(ctx.Request().Context())
+	req.Method = backend.route.Method
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	resp, done, err := backend.exchange(ctx, req)
+	if err != nil {
+		return backendResult{err: err}
+	}
+	defer done()
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return backendResult{err: fmt.Errorf("status %d", resp.StatusCode)}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBackendResponseBytes+1))
+	if err != nil {
+		return backendResult{err: err}
+	}
+	if len(data) > maxBackendResponseBytes {
+		return backendResult{err: fmt.Errorf("response larger than %d bytes", maxBackendResponseBytes)}
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return backendResult{err: fmt.Errorf("invalid JSON response: %w", err)}
+	}
+	return backendResult{value: value}
+}
+
+// deepMerge merges src into dst, recursing into objects; other values from src win
+func deepMerge(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcObject, srcOK := value.(map[string]interface{})
+		dstObject, dstOK := dst[key].(map[string]interface{})
+		if srcOK && dstOK {
+			deepMerge(dstObject, srcObject)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// lookupJSON follows a dotted path through decoded JSON objects and arrays
+func lookupJSON(value interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			value = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// formatJSON renders a decoded JSON value as text for a URL
+func formatJSON(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
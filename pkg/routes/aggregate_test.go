@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestAggregateConcurrentBackends renders query placeholders in parallel backends;
+// run with -race to catch backends sharing the request context
+func TestAggregateConcurrentBackends(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{r.URL.Path[1:]: r.URL.Query().Get("q")})
+	}))
+	defer upstream.Close()
+
+	route := Route{Path: "/search", Method: "GET"}
+	for _, name := range []string{"users", "orders", "items"} {
+		route.Backends = append(route.Backends, Backend{Target: upstream.URL + "/" + name + "?q={query.q}"})
+	}
+	gateway := newTestGateway(t, []Route{route})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			q := fmt.Sprintf("term-%d", i)
+			req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/search?q="+q, nil)
+			status, body := send(t, http.DefaultClient, req)
+			if status != http.StatusOK {
+				t.Errorf("status = %d, want 200: %s", status, body)
+				return
+			}
+
+			var merged map[string]string
+			if err := json.Unmarshal([]byte(body), &merged); err != nil {
+				t.Errorf("invalid JSON %q: %v", body, err)
+				return
+			}
+			for _, name := range []string{"users", "orders", "items"} {
+				if merged[name] != q {
+					t.Errorf("%s = %q, want %q", name, merged[name], q)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAggregateCapsRequestBody(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{r.URL.Path[1:]: len(body)})
+	}))
+	t.Cleanup(upstream.Close)
+
+	newRoute := func(path string, maxBodyBytes int64) Route {
+		return Route{
+			Path:         path,
+			Method:       http.MethodPost,
+			MaxBodyBytes: maxBodyBytes,
+			Backends:     []Backend{{Target: upstream.URL + "/users"}, {Target: upstream.URL + "/orders"}},
+		}
+	}
+	gateway := newTestGateway(t, []Route{newRoute("/default", 0), newRoute("/limited", 64)})
+
+	tests := []struct {
+		name   string
+		path   string
+		size   int
+		status int
+	}{
+		{name: "small body", path: "/default", size: 16, status: http.StatusOK},
+		{name: "over the default cap", path: "/default", size: defaultAggregateMaxBodyBytes + 1, status: http.StatusRequestEntityTooLarge},
+		{name: "over the route limit", path: "/limited", size: 65, status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, gateway.URL+tt.path, strings.NewReader(strings.Repeat("x", tt.size)))
+			status, body := send(t, http.DefaultClient, req)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %s", status, tt.status, body)
+			}
+			if status == http.StatusOK && body != fmt.Sprintf(`{"orders":%d,"users":%d}`+"\n", tt.size, tt.size) {
+				t.Errorf("body = %s, want every backend to get %d bytes", body, tt.size)
+			}
+		})
+	}
+}
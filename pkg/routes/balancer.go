@@ -0,0 +1,268 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing load balancing logic.
+// For configuring load balancing, edit the loadBalancing block in config/routes/ instead.
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// Load balancing strategies
+const (
+	StrategyRoundRobin       = "round-robin"
+	StrategyWeightedRandom   = "weighted-random"
+	StrategyLeastConnections = "least-connections"
+	StrategyConsistentHash   = "consistent-hash"
+)
+
+// Consistent hash key sources
+const (
+	HashOnHeader = "header"
+	HashOnCookie = "cookie"
+	HashOnIP     = "ip"
+)
+
+// upstream is a selectable route target
+type upstream struct {
+	url     string
+	target  *TargetTemplate
+	weight  int
+	active  int64
+	health  *targetHealth
+	breaker *circuitBreaker
+	// client is the shared client of a target with a static host
+	client *http.Client
+}
+
+// acquire admits a request to the upstream unless its circuit is open
+func (u *upstream) acquire() bool {
+	return u.breaker == nil || u.breaker.acquire()
+}
+
+// release gives back an admission for a request that never reached the upstream
+func (u *upstream) release() {
+	if u.breaker != nil {
+		u.breaker.release()
+	}
+}
+
+// observe records the outcome of an admitted request for health checks and circuit breaking
+func (u *upstream) observe(success bool) {
+	if u.health != nil {
+		u.health.observeResponse(success)
+	}
+	if u.breaker != nil {
+		u.breaker.record(success)
+	}
+}
+
+// balancer selects an upstream among the candidates for a request
+type balancer interface {
+	pick(ctx framework.Context, candidates []*upstream) *upstream
+}
+
+// routeTargets returns the route targets, treating a single target as one of weight 1
+func routeTargets(route Route) []Target {
+	if len(route.Targets) > 0 {
+		return route.Targets
+	}
+	if route.Target != "" {
+		return []Target{{URL: route.Target}}
+	}
+	return nil
+}
+
+// newUpstreams parses route targets into selectable upstreams, leaving out drained targets
+func newUpstreams(targets []Target) ([]*upstream, error) {
+	upstreams := make([]*upstream, 0, len(targets))
+
+	for _, t := range targets {
+		if t.weight() == 0 {
+			continue
+		}
+
+		tmpl, err := ParseTargetTemplate(t.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		upstreams = append(upstreams, &upstream{
+			url:    t.URL,
+			target: tmpl,
+			weight: t.weight(),
+		})
+	}
+
+	return upstreams, nil
+}
+
+// newBalancer creates a balancer for the configured strategy
+func newBalancer(config *LoadBalancingConfig) (balancer, error) {
+	if config == nil {
+		return &roundRobinBalancer{}, nil
+	}
+
+	switch config.Strategy {
+	case "", StrategyRoundRobin:
+		return &roundRobinBalancer{}, nil
+	case StrategyWeightedRandom:
+		return &weightedRandomBalancer{}, nil
+	case StrategyLeastConnections:
+		return &leastConnectionsBalancer{}, nil
+	case StrategyConsistentHash:
+		switch config.HashOn {
+		case HashOnHeader, HashOnCookie:
+			if config.HashKey == "" {
+				return nil, fmt.Errorf("consistent-hash on %s requires hashKey", config.HashOn)
+			}
+		case HashOnIP:
+		default:
+			return nil, fmt.Errorf("unsupported hashOn: %q", config.HashOn)
+		}
+		return &consistentHashBalancer{hashOn: config.HashOn, hashKey: config.HashKey}, nil
+	default:
+		return nil, fmt.Errorf("unsupported load balancing strategy: %s", config.Strategy)
+	}
+}
+
+// totalWeight sums candidate weights
+func totalWeight(candidates []*upstream) int {
+	total := 0
+	for _, u := range candidates {
+		total += u.weight
+	}
+	return total
+}
+
+// pickByWeight returns the candidate owning position n of the cumulative weights
+func pickByWeight(candidates []*upstream, n int) *upstream {
+	for _, u := range candidates {
+		if n < u.weight {
+			return u
+		}
+		n -= u.weight
+	}
+	return candidates[len(candidates)-1]
+}
+
+// roundRobinBalancer cycles through candidates proportionally to their weights
+type roundRobinBalancer struct {
+	counter uint64
+}
+
+func (b *roundRobinBalancer) pick(ctx framework.Context, candidates []*upstream) *upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+	n := atomic.AddUint64(&b.counter, 1) - 1
+	return pickByWeight(candidates, int(n%uint64(totalWeight(candidates))))
+}
+
+// weightedRandomBalancer picks candidates at random proportionally to their weights
+type weightedRandomBalancer struct{}
+
+func (b *weightedRandomBalancer) pick(ctx framework.Context, candidates []*upstream) *upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+	return pickByWeight(candidates, rand.IntN(totalWeight(candidates)))
+}
+
+// leastConnectionsBalancer picks the candidate with the fewest in-flight requests per weight
+type leastConnectionsBalancer struct{}
+
+func (b *leastConnectionsBalancer) pick(ctx framework.Context, candidates []*upstream) *upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Start at a random offset so ties don't always favor the first target
+	offset := rand.IntN(len(candidates))
+	best := candidates[offset]
+	bestActive := atomic.LoadInt64(&best.active)
+
+	for i := 1; i < len(candidates); i++ {
+		u := candidates[(offset+i)%len(candidates)]
+		active := atomic.LoadInt64(&u.active)
+		if active*int64(best.weight) < bestActive*int64(u.weight) {
+			best, bestActive = u, active
+		}
+	}
+
+	return best
+}
+
+// consistentHashBalancer maps a request key to a stable candidate using rendezvous hashing,
+// so removing a target only remaps the keys that were assigned to it
+type consistentHashBalancer struct {
+	hashOn  string
+	hashKey string
+}
+
+func (b *consistentHashBalancer) pick(ctx framework.Context, candidates []*upstream) *upstream {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	key := b.key(ctx)
+	if key == "" {
+		return pickByWeight(candidates, rand.IntN(totalWeight(candidates)))
+	}
+
+	var best *upstream
+	bestScore := math.Inf(-1)
+	for _, u := range candidates {
+		if score := rendezvousScore(key, u.url, u.weight); score > bestScore {
+			best, bestScore = u, score
+		}
+	}
+
+	return best
+}
+
+// rendezvousScore scores a key against a weighted choice; the highest score wins.
+// Changing one choice's weight only moves keys onto or off that choice.
+func rendezvousScore(key, choice string, weight int) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h.Write([]byte{0})
+	h.Write([]byte(choice))
+
+	// Weighted rendezvous score: -w / ln(x) with x uniform in (0, 1)
+	x := (float64(mix64(h.Sum64())>>11) + 0.5) / (1 << 53)
+	return -float64(weight) / math.Log(x)
+}
+
+// mix64 spreads hash bits so similar keys produce unrelated scores (splitmix64 finalizer)
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// key extracts the hash key from the request
+func (b *consistentHashBalancer) key(ctx framework.Context) string {
+	req := ctx.Request()
+
+	switch b.hashOn {
+	case HashOnHeader:
+		return req.Header.Get(b.hashKey)
+	case HashOnCookie:
+		if cookie, err := req.Cookie(b.hashKey); err == nil {
+			return cookie.Value
+		}
+		return ""
+	default:
+		return remoteIP(req)
+	}
+}
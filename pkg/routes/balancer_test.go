@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// weight returns a pointer to a target weight
+func weight(w int) *int {
+	return &w
+}
+
+func TestDrainedTargets(t *testing.T) {
+	upstreams, err := newUpstreams([]Target{
+		{URL: "http://a:8081/"},
+		{URL: "http://b:8081/", Weight: weight(0)},
+		{URL: "http://c:8081/", Weight: weight(3)},
+	})
+	if err != nil {
+		t.Fatalf("newUpstreams: %v", err)
+	}
+
+	got := make(map[string]int)
+	for _, u := range upstreams {
+		got[u.url] = u.weight
+	}
+	want := map[string]int{"http://a:8081/": 1, "http://c:8081/": 3}
+	if len(got) != len(want) || got["http://a:8081/"] != 1 || got["http://c:8081/"] != 3 {
+		t.Errorf("upstreams = %v, want %v", got, want)
+	}
+
+	err = validateRoute(Route{Path: "/", Method: "GET", Targets: []Target{{URL: "http://a:8081/", Weight: weight(0)}}})
+	if err == nil || !strings.Contains(err.Error(), "positive weight") {
+		t.Errorf("validateRoute with only drained targets: err = %v", err)
+	}
+}
+
+func TestBalancerSelection(t *testing.T) {
+	newCandidates := func(weights ...int) []*upstream {
+		candidates := make([]*upstream, len(weights))
+		for i, w := range weights {
+			candidates[i] = &upstream{url: string(rune('a'+i)) + ".example", weight: w}
+		}
+		return candidates
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		weights  []int
+		active   []int64
+		picks    int
+		// want is the expected share of picks per candidate, within tolerance
+		want      []float64
+		tolerance float64
+	}{
+		{name: "round-robin is exact", strategy: StrategyRoundRobin, weights: []int{3, 1}, picks: 400, want: []float64{0.75, 0.25}},
+		{name: "round-robin equal", strategy: StrategyRoundRobin, weights: []int{1, 1, 1}, picks: 300, want: []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}},
+		{name: "weighted-random", strategy: StrategyWeightedRandom, weights: []int{3, 1}, picks: 20000, want: []float64{0.75, 0.25}, tolerance: 0.02},
+		{name: "least-connections picks idle", strategy: StrategyLeastConnections, weights: []int{1, 1, 1}, active: []int64{4, 0, 2}, picks: 50, want: []float64{0, 1, 0}},
+		{name: "least-connections per weight", strategy: StrategyLeastConnections, weights: []int{4, 1}, active: []int64{6, 2}, picks: 50, want: []float64{1, 0}},
+		{name: "least-connections spreads ties", strategy: StrategyLeastConnections, weights: []int{1, 1}, active: []int64{0, 0}, picks: 4000, want: []float64{0.5, 0.5}, tolerance: 0.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newBalancer(&LoadBalancingConfig{Strategy: tt.strategy})
+			if err != nil {
+				t.Fatalf("newBalancer: %v", err)
+			}
+			candidates := newCandidates(tt.weights...)
+			for i, active := range tt.active {
+				candidates[i].active = active
+			}
+
+			counts := make(map[*upstream]int)
+			ctx := newTestContext(httptest.NewRequest("GET", "/", nil))
+			for i := 0; i < tt.picks; i++ {
+				counts[b.pick(ctx, candidates)]++
+			}
+
+			for i, u := range candidates {
+				share := float64(counts[u]) / float64(tt.picks)
+				if math.Abs(share-tt.want[i]) > tt.tolerance+1e-9 {
+					t.Errorf("candidate %d got %.3f of picks, want %.3f", i, share, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	candidates := []*upstream{
+		{url: "http://a:8081", weight: 1},
+		{url: "http://b:8081", weight: 1},
+		{url: "http://c:8081", weight: 2},
+	}
+
+	tests := []struct {
+		name   string
+		config LoadBalancingConfig
+		set    func(req *http.Request, key string)
+	}{
+		{
+			name:   "header",
+			config: LoadBalancingConfig{Strategy: StrategyConsistentHash, HashOn: HashOnHeader, HashKey: "X-User"},
+			set:    func(req *http.Request, key string) { req.Header.Set("X-User", key) },
+		},
+		{
+			name:   "cookie",
+			config: LoadBalancingConfig{Strategy: StrategyConsistentHash, HashOn: HashOnCookie, HashKey: "session"},
+			set:    func(req *http.Request, key string) { req.AddCookie(&http.Cookie{Name: "session", Value: key}) },
+		},
+		{
+			name:   "ip",
+			config: LoadBalancingConfig{Strategy: StrategyConsistentHash, HashOn: HashOnIP},
+			set:    func(req *http.Request, key string) { req.RemoteAddr = key + ":1234" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := newBalancer(&tt.config)
+			if err != nil {
+				t.Fatalf("newBalancer: %v", err)
+			}
+
+			counts := make(map[*upstream]int)
+			for i := 0; i < 2000; i++ {
+				key := fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+				pick := func(candidates []*upstream) *upstream {
+					req := httptest.NewRequest("GET", "/", nil)
+					tt.set(req, key)
+					return b.pick(newTestContext(req), candidates)
+				}
+
+				first := pick(candidates)
+				if again := pick(candidates); again != first {
+					t.Fatalf("key %s moved from %s to %s", key, first.url, again.url)
+				}
+				counts[first]++
+
+				// Removing a target only remaps the keys it owned
+				if first != candidates[0] {
+					if without := pick(candidates[1:]); without != first {
+						t.Fatalf("key %s moved from %s to %s when another target left", key, first.url, without.url)
+					}
+				}
+			}
+
+			if share := float64(counts[candidates[2]]) / 2000; share < 0.44 || share > 0.56 {
+				t.Errorf("weight 2 target got %.3f of keys, want about 0.5", share)
+			}
+		})
+	}
+}
+
+func TestNewBalancerErrors(t *testing.T) {
+	tests := []struct {
+		config LoadBalancingConfig
+		err    string
+	}{
+		{config: LoadBalancingConfig{Strategy: "fastest"}, err: "unsupported load balancing strategy"},
+		{config: LoadBalancingConfig{Strategy: StrategyConsistentHash, HashOn: HashOnHeader}, err: "requires hashKey"},
+		{config: LoadBalancingConfig{Strategy: StrategyConsistentHash, HashOn: "path"}, err: "unsupported hashOn"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err, func(t *testing.T) {
+			if _, err := newBalancer(&tt.config); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing circuit breaking logic.
+// For configuring circuit breakers, edit the circuitBreaker block in config/routes/ instead.
+
+import (
+	"encoding/json"
+	"log"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Default circuit breaker settings
+const (
+	defaultBreakerFailureRatio     = 0.5
+	defaultBreakerMinRequests      = 20
+	defaultBreakerWindow           = 60 * time.Second
+	defaultBreakerOpenDuration     = 30 * time.Second
+	defaultBreakerHalfOpenRequests = 1
+)
+
+// BreakerStatus is a point-in-time view of a circuit breaker
+type BreakerStatus struct {
+	Upstream string    `json:"upstream"`
+	State    string    `json:"state"`
+	Requests int       `json:"requests"`
+	Failures int       `json:"failures"`
+	Since    time.Time `json:"since"`
+}
+
+// BreakerRegistry shares circuit breakers per upstream origin and configuration
+type BreakerRegistry struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+// NewBreakerRegistry creates a new circuit breaker registry
+func NewBreakerRegistry() *BreakerRegistry {
+	return &BreakerRegistry{
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// register returns the circuit breaker for an upstream origin
+func (r *BreakerRegistry) register(origin *url.URL, config *CircuitBreakerConfig) *circuitBreaker {
+	fingerprint, _ := json.Marshal(config)
+	key := origin.Scheme + "://" + origin.Host + "|" + string(fingerprint)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if breaker, exists := r.breakers[key]; exists {
+		return breaker
+	}
+
+	breaker := &circuitBreaker{
+		name:        origin.Scheme + "://" + origin.Host,
+		config:      config,
+		state:       BreakerClosed,
+		since:       time.Now(),
+		windowStart: time.Now(),
+	}
+	r.breakers[key] = breaker
+
+	return breaker
+}
+
+// Statuses returns the state of every circuit breaker, sorted by upstream
+func (r *BreakerRegistry) Statuses() []BreakerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]BreakerStatus, 0, len(r.breakers))
+	for _, breaker := range r.breakers {
+		statuses = append(statuses, breaker.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Upstream < statuses[j].Upstream
+	})
+
+	return statuses
+}
+
+// circuitBreaker stops traffic to an upstream whose failure ratio crosses a threshold
+type circuitBreaker struct {
+	mu     sync.Mutex
+	name   string
+	config *CircuitBreakerConfig
+
+	state string
+	since time.Time
+
+	// Closed state counters, reset every window
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	// Half-open probe accounting
+	probes    int
+	successes int
+}
+
+// ready reports whether the breaker would admit a request, without reserving a probe
+func (b *circuitBreaker) ready() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	switch b.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		return b.probes < intOr(b.config.HalfOpenRequests, defaultBreakerHalfOpenRequests)
+	default:
+		return true
+	}
+}
+
+// acquire admits a request, reserving a probe slot when half-open
+func (b *circuitBreaker) acquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	switch b.state {
+	case BreakerOpen:
+		return false
+	case BreakerHalfOpen:
+		if b.probes >= intOr(b.config.HalfOpenRequests, defaultBreakerHalfOpenRequests) {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+// release returns a reserved probe slot for a request that never reached the upstream
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+// record reports the outcome of an admitted request
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	switch b.state {
+	case BreakerHalfOpen:
+		if !success {
+			b.transition(BreakerOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= intOr(b.config.HalfOpenRequests, defaultBreakerHalfOpenRequests) {
+			b.transition(BreakerClosed)
+		}
+
+	case BreakerClosed:
+		b.requests++
+		if !success {
+			b.failures++
+		}
+
+		minRequests := intOr(b.config.MinRequests, defaultBreakerMinRequests)
+		ratio := b.config.FailureRatio
+		if ratio <= 0 {
+			ratio = defaultBreakerFailureRatio
+		}
+		if b.requests >= minRequests && float64(b.failures)/float64(b.requests) >= ratio {
+			b.transition(BreakerOpen)
+		}
+	}
+}
+
+// advance applies time-based transitions; the caller must hold the lock
+func (b *circuitBreaker) advance() {
+	now := time.Now()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.since) >= durationOr(b.config.OpenDuration, defaultBreakerOpenDuration) {
+			b.transition(BreakerHalfOpen)
+		}
+	case BreakerClosed:
+		if now.Sub(b.windowStart) >= durationOr(b.config.Window, defaultBreakerWindow) {
+			b.windowStart = now
+			b.requests = 0
+			b.failures = 0
+		}
+	}
+}
+
+// transition moves the breaker to a new state; the caller must hold the lock
+func (b *circuitBreaker) transition(state string) {
+	log.Printf("Circuit breaker for %s: %s -> %s (requests=%d, failures=%d)", b.name, b.state, state, b.requests, b.failures)
+
+	b.state = state
+	b.since = time.Now()
+	b.windowStart = b.since
+	b.requests = 0
+	b.failures = 0
+	b.probes = 0
+	b.successes = 0
+}
+
+// status returns a snapshot of the breaker
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	return BreakerStatus{
+		Upstream: b.name,
+		State:    b.state,
+		Requests: b.requests,
+		Failures: b.failures,
+		Since:    b.since,
+	}
+}
+
+// breakerReady reports whether the upstream's circuit admits requests
+func (u *upstream) breakerReady() bool {
+	return u.breaker == nil || u.breaker.ready()
+}
+
+// closedUpstreams returns the candidates whose circuit admits requests
+func closedUpstreams(candidates []*upstream) []*upstream {
+	closed := make([]*upstream, 0, len(candidates))
+	for _, u := range candidates {
+		if u.breakerReady() {
+			closed = append(closed, u)
+		}
+	}
+	return closed
+}
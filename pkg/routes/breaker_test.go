@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	window := Duration(time.Minute)
+	open := Duration(30 * time.Second)
+	config := func(halfOpen int) *CircuitBreakerConfig {
+		return &CircuitBreakerConfig{FailureRatio: 0.5, MinRequests: 4, Window: &window, OpenDuration: &open, HalfOpenRequests: halfOpen}
+	}
+
+	// Steps: ok and fail record an outcome, admit and deny acquire a request expecting
+	// it to be admitted or not, release returns a probe, and window and open let the
+	// window or the open duration elapse
+	tests := []struct {
+		name   string
+		config *CircuitBreakerConfig
+		steps  []string
+		state  string
+	}{
+		{name: "closed below min requests", config: config(1), steps: []string{"fail", "fail", "fail"}, state: BreakerClosed},
+		{name: "closed below ratio", config: config(1), steps: []string{"ok", "ok", "ok", "fail", "ok"}, state: BreakerClosed},
+		{name: "opens at ratio", config: config(1), steps: []string{"ok", "fail", "ok", "fail", "deny"}, state: BreakerOpen},
+		{name: "window resets counters", config: config(1), steps: []string{"fail", "fail", "fail", "window", "fail"}, state: BreakerClosed},
+		{name: "stays open until the duration elapses", config: config(1), steps: []string{"fail", "fail", "fail", "fail", "deny", "deny"}, state: BreakerOpen},
+		{name: "half-open after the duration", config: config(1), steps: []string{"fail", "fail", "fail", "fail", "open", "admit", "deny"}, state: BreakerHalfOpen},
+		{name: "probe success closes", config: config(1), steps: []string{"fail", "fail", "fail", "fail", "open", "admit", "ok", "admit"}, state: BreakerClosed},
+		{name: "probe failure reopens", config: config(1), steps: []string{"fail", "fail", "fail", "fail", "open", "admit", "fail", "deny"}, state: BreakerOpen},
+		{name: "released probe is reusable", config: config(1), steps: []string{"fail", "fail", "fail", "fail", "open", "admit", "release", "admit"}, state: BreakerHalfOpen},
+		{name: "several probes must succeed", config: config(2), steps: []string{"fail", "fail", "fail", "fail", "open", "admit", "admit", "deny", "ok"}, state: BreakerHalfOpen},
+		{name: "all probes succeed", config: config(2), steps: []string{"fail", "fail", "fail", "fail", "open", "admit", "admit", "ok", "ok"}, state: BreakerClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreakerRegistry().register(&url.URL{Scheme: "http", Host: "users:8081"}, tt.config)
+
+			for i, step := range tt.steps {
+				switch step {
+				case "ok", "fail":
+					b.record(step == "ok")
+				case "admit", "deny":
+					if admitted := b.acquire(); admitted != (step == "admit") {
+						t.Fatalf("step %d: acquire = %t, want %t (state %s)", i, admitted, step == "admit", b.status().State)
+					}
+				case "release":
+					b.release()
+				case "window":
+					b.mu.Lock()
+					b.windowStart = b.windowStart.Add(-time.Duration(window))
+					b.mu.Unlock()
+				case "open":
+					b.mu.Lock()
+					b.since = b.since.Add(-time.Duration(open))
+					b.mu.Unlock()
+				default:
+					t.Fatalf("unknown step %q", step)
+				}
+			}
+
+			if state := b.status().State; state != tt.state {
+				t.Errorf("state = %s, want %s", state, tt.state)
+			}
+		})
+	}
+}
+
+func TestBreakerRegistryShares(t *testing.T) {
+	r := NewBreakerRegistry()
+	config := &CircuitBreakerConfig{MinRequests: 1}
+
+	a := r.register(&url.URL{Scheme: "http", Host: "users:8081"}, config)
+	if b := r.register(&url.URL{Scheme: "http", Host: "users:8081", Path: "/other"}, config); b != a {
+		t.Errorf("same origin and config got a different breaker")
+	}
+	if b := r.register(&url.URL{Scheme: "http", Host: "orders:8081"}, config); b == a {
+		t.Errorf("different origin shares a breaker")
+	}
+	if b := r.register(&url.URL{Scheme: "http", Host: "users:8081"}, &CircuitBreakerConfig{MinRequests: 5}); b == a {
+		t.Errorf("different config shares a breaker")
+	}
+
+	if statuses := r.Statuses(); len(statuses) != 3 || statuses[0].Upstream != "http://orders:8081" {
+		t.Errorf("Statuses = %+v", statuses)
+	}
+}
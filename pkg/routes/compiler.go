@@ -6,11 +6,19 @@ package routes
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
+// MethodAny expands to every supported method
+const MethodAny = "ANY"
+
+// supportedMethods are the methods routes can be registered for; CONNECT is not proxied
+var supportedMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
 // Compiler compiles route configurations from multiple files
 type Compiler struct {
 	configDir  string
@@ -38,10 +46,13 @@ func (c *Compiler) Compile() error {
 	}
 
 	// Load global config first
+	global := &GlobalConfig{}
 	if c.globalFile != "" {
-		if err := c.loadGlobalConfig(compiled); err != nil {
+		loaded, err := c.loadGlobalConfig(compiled)
+		if err != nil {
 			return fmt.Errorf("failed to load global config: %w", err)
 		}
+		global = loaded
 	}
 
 	// Read domain route configs
@@ -66,14 +77,76 @@ func (c *Compiler) Compile() error {
 			return fmt.Errorf("failed to parse file %s: %w", file.Name(), err)
 		}
 
-		// Process routes
-		for _, route := range config.Routes {
+		switch config.TrailingSlash {
+		case "", TrailingSlashStrip, TrailingSlashAdd:
+		default:
+			return fmt.Errorf("invalid trailingSlash %q in %s", config.TrailingSlash, file.Name())
+		}
+
+		// Expand method lists into one route per method
+		routes, err := expandMethods(append(config.Routes, passthroughRoutes(config)...))
+		if err != nil {
+			return fmt.Errorf("invalid routes in %s: %w", file.Name(), err)
+		}
+
+		// Process routes, then the passthrough catch-alls
+		aliases := make([]Route, 0)
+		for _, route := range routes {
 			compiledRoute := route
 
 			// Prepend base path if defined
 			if config.BasePath != "" {
 				compiledRoute.Path = config.BasePath + route.Path
 			}
+			compiledRoute.Path = canonicalPath(compiledRoute.Path, config.TrailingSlash)
+
+			// Redirect plain HTTP to HTTPS if the domain asks for it
+			if config.HTTPSRedirect {
+				compiledRoute.HTTPSRedirect = true
+			}
+
+			// Merge domain-level load balancing if route doesn't have its own
+			if compiledRoute.LoadBalancing == nil {
+				compiledRoute.LoadBalancing = config.LoadBalancing
+			}
+
+			// Merge domain-level health checks if route doesn't have its own
+			if compiledRoute.HealthCheck == nil {
+				compiledRoute.HealthCheck = config.HealthCheck
+			}
+
+			// Merge domain-level circuit breaker if route doesn't have its own
+			if compiledRoute.CircuitBreaker == nil {
+				compiledRoute.CircuitBreaker = config.CircuitBreaker
+			}
+
+			// Merge domain-level retry policy if route doesn't have its own
+			if compiledRoute.Retry == nil {
+				compiledRoute.Retry = config.Retry
+			}
+
+			// Inherit the domain's virtual hosts if the route doesn't have its own
+			if len(compiledRoute.Hosts) == 0 {
+				compiledRoute.Hosts = config.Hosts
+			}
+
+			// Merge domain-level path rewriting into routes that take the upstream path from the request
+			if compiledRoute.inheritsRewrite() {
+				if compiledRoute.StripPrefix == "" {
+					compiledRoute.StripPrefix = config.StripPrefix
+				}
+				if compiledRoute.AddPrefix == "" {
+					compiledRoute.AddPrefix = config.AddPrefix
+				}
+				if compiledRoute.Rewrite == nil {
+					compiledRoute.Rewrite = config.Rewrite
+				}
+			}
+
+			// Validate targets, their placeholders and upstream policies
+			if err := validateRoute(compiledRoute); err != nil {
+				return fmt.Errorf("invalid route %s %s in %s: %w", compiledRoute.Method, compiledRoute.Path, file.Name(), err)
+			}
 
 			// Merge domain-level middlewares if route doesn't have its own
 			if compiledRoute.Middlewares == nil && config.Middlewares != nil {
@@ -90,10 +163,50 @@ func (c *Compiler) Compile() error {
 				}
 			}
 
+			// Inherit the request timeout: route, then domain, then global
+			if compiledRoute.Timeout == nil {
+				compiledRoute.Timeout = config.Timeout
+			}
+			if compiledRoute.Timeout == nil {
+				compiledRoute.Timeout = global.Timeout
+			}
+
+			// Merge transport settings: route, then domain, then global
+			compiledRoute.Transport = mergeTransport(route.Transport, config.Transport, global.Transport)
+			if _, err := newTransport(compiledRoute.Transport, compiledRoute.upstreamProtocol()); err != nil {
+				return fmt.Errorf("invalid route %s %s in %s: %w", compiledRoute.Method, compiledRoute.Path, file.Name(), err)
+			}
+
+			// Merge forwarding headers: route, then domain, then global
+			compiledRoute.Forwarding = mergeForwarding(route.Forwarding, config.Forwarding, global.Forwarding)
+			if _, err := newForwarder(compiledRoute.Forwarding); err != nil {
+				return fmt.Errorf("invalid route %s %s in %s: %w", compiledRoute.Method, compiledRoute.Path, file.Name(), err)
+			}
+
 			compiled.Routes = append(compiled.Routes, compiledRoute)
+
+			// Redirect the other spelling of the path to the canonical one
+			if alias, ok := trailingSlashAlias(compiledRoute, config.TrailingSlash); ok {
+				aliases = append(aliases, alias)
+			}
+		}
+
+		// Explicit routes win over generated trailing slash redirects
+		declared := make(map[string]bool)
+		for _, route := range compiled.Routes {
+			declared[route.Method+" "+route.Path] = true
+		}
+		for _, alias := range aliases {
+			if !declared[alias.Method+" "+alias.Path] {
+				declared[alias.Method+" "+alias.Path] = true
+				compiled.Routes = append(compiled.Routes, alias)
+			}
 		}
 	}
 
+	// Specific routes win over parameters and catch-alls
+	sortRoutes(compiled.Routes)
+
 	// Write compiled routes
 	if err := os.MkdirAll(c.outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
@@ -112,20 +225,432 @@ func (c *Compiler) Compile() error {
 	return nil
 }
 
+// passthroughRoutes forwards everything under the domain's base path to its passthrough
+// upstream, keeping the request path unless the domain rewrites it
+func passthroughRoutes(config RouteConfig) []Route {
+	if config.Passthrough == "" {
+		return nil
+	}
+
+	// Explicit routes take precedence over the generated ones
+	declared := make(map[string]bool, len(config.Routes))
+	for _, route := range config.Routes {
+		methods, _ := routeMethods(route)
+		for _, method := range methods {
+			declared[method+" "+route.Path] = true
+		}
+	}
+
+	target := strings.TrimSuffix(config.Passthrough, "/")
+	paths := []string{"/*"}
+	if config.BasePath != "" {
+		paths = []string{"", "/*"}
+	}
+
+	routes := make([]Route, 0, len(paths)*len(supportedMethods))
+	for _, path := range paths {
+		route := Route{Path: path, Target: target}
+		if config.StripPrefix == "" && config.AddPrefix == "" && config.Rewrite == nil {
+			route.Target = target + config.BasePath + path
+		}
+		for _, method := range supportedMethods {
+			if declared[method+" "+path] {
+				continue
+			}
+			route.Method = method
+			routes = append(routes, route)
+		}
+	}
+
+	return routes
+}
+
+// routeMethods returns the concrete methods of a route's method or methods
+func routeMethods(route Route) ([]string, error) {
+	if route.Method != "" && len(route.Methods) > 0 {
+		return nil, fmt.Errorf("method and methods are mutually exclusive")
+	}
+
+	declared := route.Methods
+	if route.Method != "" {
+		declared = []string{route.Method}
+	}
+	if len(declared) == 0 {
+		return nil, fmt.Errorf("route has no method")
+	}
+
+	methods := make([]string, 0, len(declared))
+	seen := make(map[string]bool)
+	for _, method := range declared {
+		method = strings.ToUpper(method)
+		expanded := []string{method}
+		if method == MethodAny {
+			expanded = supportedMethods
+		} else if !isSupportedMethod(method) {
+			return nil, fmt.Errorf("unsupported method: %s", method)
+		}
+
+		for _, m := range expanded {
+			if !seen[m] {
+				seen[m] = true
+				methods = append(methods, m)
+			}
+		}
+	}
+
+	return methods, nil
+}
+
+// expandMethods turns every route into one route per concrete method
+func expandMethods(routes []Route) ([]Route, error) {
+	expanded := make([]Route, 0, len(routes))
+
+	for _, route := range routes {
+		methods, err := routeMethods(route)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route.Path, err)
+		}
+		for _, method := range methods {
+			single := route
+			single.Method = method
+			single.Methods = nil
+			expanded = append(expanded, single)
+		}
+	}
+
+	return expanded, nil
+}
+
+// isSupportedMethod reports whether routes can be registered for method
+func isSupportedMethod(method string) bool {
+	for _, m := range supportedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// sortRoutes orders routes segment by segment: static before :param before catch-all
+func sortRoutes(routes []Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return lessPath(routes[i].Path, routes[j].Path)
+	})
+}
+
+// lessPath reports whether path a is more specific than path b
+func lessPath(a, b string) bool {
+	as := strings.Split(strings.Trim(a, "/"), "/")
+	bs := strings.Split(strings.Trim(b, "/"), "/")
+	for k := 0; k < len(as) && k < len(bs); k++ {
+		if ka, kb := segmentRank(as[k]), segmentRank(bs[k]); ka != kb {
+			return ka < kb
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// segmentRank ranks how specific a path segment is, lowest first
+func segmentRank(segment string) int {
+	switch {
+	case segment == "*" || isNamedWildcard(segment):
+		return 2
+	case strings.HasPrefix(segment, ":"):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// validatePath checks that a catch-all is the last segment of the route path
+func validatePath(path string) error {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.Contains(segment, "*") {
+			continue
+		}
+		if segment != "*" && !isNamedWildcard(segment) {
+			return fmt.Errorf("path %q: a catch-all must be a whole * or :name* segment", path)
+		}
+		if i != len(segments)-1 {
+			return fmt.Errorf("path %q: a catch-all must be the last segment", path)
+		}
+	}
+	return nil
+}
+
+// validateRoute checks the route targets and upstream policies
+func validateRoute(route Route) error {
+	if err := validatePath(route.Path); err != nil {
+		return err
+	}
+	if _, err := newRouteMatcher(route); err != nil {
+		return err
+	}
+
+	if route.Response != nil {
+		if route.Stream || (route.Protocol != "" && route.Protocol != ProtocolHTTP) {
+			return fmt.Errorf("response filtering needs buffered http responses")
+		}
+		if _, err := newResponseFilter(route.Response); err != nil {
+			return err
+		}
+	}
+
+	if route.Request != nil {
+		if route.Protocol != "" && route.Protocol != ProtocolHTTP {
+			return fmt.Errorf("request transformation needs http requests")
+		}
+		if _, err := newRequestTransformer(route.Request, route.MaxBodyBytes); err != nil {
+			return err
+		}
+		declared, _ := pathParams(route.Path)
+		for field, value := range route.Request.Set {
+			s, _ := value.(string)
+			for _, match := range valuePlaceholder.FindAllStringSubmatch(s, -1) {
+				if match[1] == "param" && !declared[match[2]] {
+					return fmt.Errorf("request set %s references undeclared path parameter :%s", field, match[2])
+				}
+			}
+		}
+	}
+
+	if route.Mirror != nil {
+		if route.Protocol != "" && route.Protocol != ProtocolHTTP {
+			return fmt.Errorf("mirror needs http requests")
+		}
+		if _, err := newMirror(route, nil, nil); err != nil {
+			return err
+		}
+	}
+
+	if route.Redirect != nil {
+		return validateRedirectRoute(route)
+	}
+	if route.Mock != nil {
+		return validateMockRoute(route)
+	}
+	if len(route.Split) > 0 {
+		return validateSplitRoute(route)
+	}
+	if route.Sticky != nil {
+		return fmt.Errorf("sticky requires split")
+	}
+
+	if len(route.Backends) > 0 {
+		return validateAggregateRoute(route)
+	}
+	if route.Sequence && route.Target == "" && len(route.Targets) == 0 {
+		return fmt.Errorf("sequence requires backends")
+	}
+
+	if route.Target != "" && len(route.Targets) > 0 {
+		return fmt.Errorf("target and targets are mutually exclusive")
+	}
+
+	targets := routeTargets(route)
+	if len(targets) == 0 {
+		return fmt.Errorf("route has no target")
+	}
+
+	total := 0
+	for _, t := range targets {
+		if t.weight() < 0 {
+			return fmt.Errorf("target %q has negative weight", t.URL)
+		}
+		total += t.weight()
+
+		tmpl, err := ParseTargetTemplate(t.URL)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Validate(route.Path); err != nil {
+			return err
+		}
+		if len(tmpl.Responses()) > 0 && !route.Sequence {
+			return fmt.Errorf("target %q references a response outside of a sequence", t.URL)
+		}
+		if route.rewritesPath() && (len(tmpl.Params()) > 0 || tmpl.HasWildcard()) {
+			return fmt.Errorf("target %q can't use path placeholders when the path is rewritten", t.URL)
+		}
+	}
+	if total == 0 {
+		return fmt.Errorf("route needs a target with positive weight")
+	}
+
+	if route.rewritesPath() {
+		if route.Transcode != nil {
+			return fmt.Errorf("transcode can't be combined with path rewriting")
+		}
+		if _, err := newPathRewriter(route); err != nil {
+			return err
+		}
+	}
+
+	if _, err := newBalancer(route.LoadBalancing); err != nil {
+		return err
+	}
+
+	if route.HealthCheck != nil && route.HealthCheck.Active != nil && route.HealthCheck.Active.Path == "" {
+		return fmt.Errorf("active health check requires a path")
+	}
+
+	if route.CircuitBreaker != nil && route.CircuitBreaker.FailureRatio > 1 {
+		return fmt.Errorf("circuit breaker failureRatio must be between 0 and 1")
+	}
+
+	if _, err := newRetryPolicy(route.Retry); err != nil {
+		return err
+	}
+
+	switch route.Protocol {
+	case "", ProtocolHTTP, ProtocolWebSocket, ProtocolGRPC:
+	default:
+		return fmt.Errorf("unsupported protocol: %q", route.Protocol)
+	}
+
+	if route.Transcode != nil {
+		if route.Protocol != "" && route.Protocol != ProtocolHTTP {
+			return fmt.Errorf("transcode routes must use the http protocol")
+		}
+		for _, t := range targets {
+			target, err := url.Parse(t.URL)
+			if err != nil || strings.Trim(target.Path, "/") != "" {
+				return fmt.Errorf("transcode target %q must not have a path", t.URL)
+			}
+		}
+		if _, err := newTranscoder(route); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateRedirectRoute checks a redirect route, which has no upstream
+func validateRedirectRoute(route Route) error {
+	if route.Target != "" || len(route.Targets) > 0 || len(route.Backends) > 0 || len(route.Split) > 0 ||
+		route.Transcode != nil || route.Mock != nil || route.Mirror != nil {
+		return fmt.Errorf("redirect can't be combined with an upstream or mock")
+	}
+	if route.Request != nil || route.Response != nil || route.rewritesPath() {
+		return fmt.Errorf("redirect can't be combined with transformation or path rewriting")
+	}
+
+	_, err := newRedirect(route)
+	return err
+}
+
+// validateMockRoute checks a mock route, which has no upstream
+func validateMockRoute(route Route) error {
+	if route.Target != "" || len(route.Targets) > 0 || len(route.Backends) > 0 || len(route.Split) > 0 || route.Transcode != nil {
+		return fmt.Errorf("mock can't be combined with an upstream")
+	}
+	if route.Mirror != nil || route.rewritesPath() {
+		return fmt.Errorf("mock can't be combined with mirror or path rewriting")
+	}
+	if route.Protocol != "" && route.Protocol != ProtocolHTTP {
+		return fmt.Errorf("mock needs the http protocol")
+	}
+
+	mock, err := newMockResponse(route.Mock)
+	if err != nil {
+		return err
+	}
+
+	declared, _ := pathParams(route.Path)
+	for _, text := range append([]string{string(mock.body)}, mapValues(mock.headers)...) {
+		for _, match := range valuePlaceholder.FindAllStringSubmatch(text, -1) {
+			if match[1] == "param" && !declared[match[2]] {
+				return fmt.Errorf("mock references undeclared path parameter :%s", match[2])
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateSplitRoute checks a split route and each of its variants
+func validateSplitRoute(route Route) error {
+	if route.Target != "" || len(route.Targets) > 0 || len(route.Backends) > 0 {
+		return fmt.Errorf("split can't be combined with target, targets or backends")
+	}
+
+	if _, err := newSplitSelector(route); err != nil {
+		return err
+	}
+
+	for i, sub := range splitRoutes(route) {
+		if err := validateRoute(sub); err != nil {
+			return fmt.Errorf("split variant %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// validateAggregateRoute checks an aggregated route and each of its backends
+func validateAggregateRoute(route Route) error {
+	if route.Target != "" || len(route.Targets) > 0 {
+		return fmt.Errorf("backends can't be combined with target or targets")
+	}
+	if route.Protocol != "" && route.Protocol != ProtocolHTTP {
+		return fmt.Errorf("backends require the http protocol")
+	}
+	if route.Transcode != nil {
+		return fmt.Errorf("backends can't be combined with transcode")
+	}
+	if route.rewritesPath() {
+		return fmt.Errorf("backends can't be combined with path rewriting")
+	}
+
+	switch route.FailurePolicy {
+	case "", FailurePolicyFailAll, FailurePolicyPartial:
+	default:
+		return fmt.Errorf("unsupported failurePolicy: %q", route.FailurePolicy)
+	}
+
+	groups := make(map[string]bool)
+	for _, backend := range route.Backends {
+		if backend.Group != "" {
+			if groups[backend.Group] {
+				return fmt.Errorf("backend group %q is used twice", backend.Group)
+			}
+			groups[backend.Group] = true
+		}
+	}
+
+	for i, sub := range backendRoutes(route) {
+		if err := validateRoute(sub); err != nil {
+			return fmt.Errorf("backend %s: %w", sub.Target, err)
+		}
+
+		// Steps can only use responses of the steps before them
+		tmpl, _ := ParseTargetTemplate(sub.Target)
+		for _, step := range tmpl.Responses() {
+			if step >= i {
+				return fmt.Errorf("backend %s references resp%d, which isn't an earlier step", sub.Target, step)
+			}
+		}
+	}
+
+	return nil
+}
+
 // loadGlobalConfig loads global configuration
-func (c *Compiler) loadGlobalConfig(compiled *CompiledRoutes) error {
+func (c *Compiler) loadGlobalConfig(compiled *CompiledRoutes) (*GlobalConfig, error) {
 	data, err := os.ReadFile(c.globalFile)
 	if err != nil {
 		// If global file doesn't exist, it's okay
 		if os.IsNotExist(err) {
-			return nil
+			return &GlobalConfig{}, nil
 		}
-		return err
+		return nil, err
 	}
 
 	var global GlobalConfig
 	if err := json.Unmarshal(data, &global); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Set global middlewares
@@ -133,5 +658,8 @@ func (c *Compiler) loadGlobalConfig(compiled *CompiledRoutes) error {
 		compiled.Middlewares = global.Middlewares
 	}
 
-	return nil
+	// Set admin endpoints
+	compiled.Admin = global.Admin
+
+	return &global, nil
 }
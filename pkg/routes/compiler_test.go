@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortRoutes(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{
+			name:  "static before param before catch-all",
+			paths: []string{"/users/*", "/users/:id", "/users/me"},
+			want:  []string{"/users/me", "/users/:id", "/users/*"},
+		},
+		{
+			name:  "earlier segments decide first",
+			paths: []string{"/:tenant/users", "/api/:id", "/api/users"},
+			want:  []string{"/api/users", "/api/:id", "/:tenant/users"},
+		},
+		{
+			name:  "named catch-all ranks as a catch-all",
+			paths: []string{"/files/:path*", "/files/:id", "/files/readme"},
+			want:  []string{"/files/readme", "/files/:id", "/files/:path*"},
+		},
+		{
+			name:  "shorter path first on a tie",
+			paths: []string{"/users/:id/orders", "/users/:id", "/users"},
+			want:  []string{"/users", "/users/:id", "/users/:id/orders"},
+		},
+		{
+			name:  "root catch-all last",
+			paths: []string{"/*", "/health", "/:page"},
+			want:  []string{"/health", "/:page", "/*"},
+		},
+		{
+			name:  "equal ranks keep their order",
+			paths: []string{"/b/:id", "/a/:id", "/b/:id"},
+			want:  []string{"/b/:id", "/a/:id", "/b/:id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := make([]Route, len(tt.paths))
+			for i, path := range tt.paths {
+				routes[i] = Route{Path: path, Target: string(rune('a' + i))}
+			}
+
+			sortRoutes(routes)
+
+			got := make([]string, len(routes))
+			for i, route := range routes {
+				got[i] = route.Path
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Routes on the same path keep their declaration order
+	routes := []Route{{Path: "/x", Target: "first"}, {Path: "/:id"}, {Path: "/x", Target: "second"}}
+	sortRoutes(routes)
+	if routes[0].Target != "first" || routes[1].Target != "second" {
+		t.Errorf("same-path routes were reordered: %+v", routes)
+	}
+}
+
+func TestRouteMethods(t *testing.T) {
+	tests := []struct {
+		name  string
+		route Route
+		want  []string
+		err   string
+	}{
+		{name: "single method", route: Route{Method: "GET"}, want: []string{"GET"}},
+		{name: "lowercase method", route: Route{Method: "post"}, want: []string{"POST"}},
+		{name: "methods list", route: Route{Methods: []string{"get", "PUT"}}, want: []string{"GET", "PUT"}},
+		{name: "duplicates collapse", route: Route{Methods: []string{"GET", "get", "HEAD"}}, want: []string{"GET", "HEAD"}},
+		{name: "any", route: Route{Method: "ANY"}, want: supportedMethods},
+		{name: "any in a list", route: Route{Methods: []string{"POST", "any"}}, want: []string{"POST", "GET", "HEAD", "PUT", "PATCH", "DELETE", "OPTIONS"}},
+		{name: "method and methods", route: Route{Method: "GET", Methods: []string{"POST"}}, err: "mutually exclusive"},
+		{name: "no method", route: Route{}, err: "route has no method"},
+		{name: "unsupported", route: Route{Method: "CONNECT"}, err: "unsupported method: CONNECT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := routeMethods(tt.route)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("routeMethods: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("methods = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandMethods(t *testing.T) {
+	routes, err := expandMethods([]Route{
+		{Path: "/users", Methods: []string{"GET", "POST"}, Target: "http://users:8081/users"},
+		{Path: "/health", Method: "head", Target: "http://users:8081/health"},
+	})
+	if err != nil {
+		t.Fatalf("expandMethods: %v", err)
+	}
+
+	want := []string{"GET /users", "POST /users", "HEAD /health"}
+	got := make([]string, len(routes))
+	for i, route := range routes {
+		got[i] = route.Method + " " + route.Path
+		if route.Methods != nil {
+			t.Errorf("%s still lists methods %v", got[i], route.Methods)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("routes = %v, want %v", got, want)
+	}
+
+	if _, err := expandMethods([]Route{{Path: "/x", Method: "TRACE"}}); err == nil || !strings.Contains(err.Error(), "route /x") {
+		t.Errorf("err = %v, want it to name the route", err)
+	}
+}
+
+// compileDomain compiles a single domain file and returns its routes
+func compileDomain(t *testing.T, domain string) ([]Route, error) {
+	t.Helper()
+
+	configDir, outputDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(configDir, "domain.json"), []byte(domain), 0644); err != nil {
+		t.Fatalf("failed to write domain: %v", err)
+	}
+	if err := NewCompiler(configDir, outputDir, "").Compile(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "routes.json"))
+	if err != nil {
+		t.Fatalf("failed to read compiled routes: %v", err)
+	}
+	var compiled CompiledRoutes
+	if err := json.Unmarshal(data, &compiled); err != nil {
+		t.Fatalf("failed to parse compiled routes: %v", err)
+	}
+	return compiled.Routes, nil
+}
+
+func TestCompileInheritsDomainRewrite(t *testing.T) {
+	tests := []struct {
+		name        string
+		route       string
+		stripPrefix string
+	}{
+		{
+			name:        "plain target",
+			route:       `{"path":"/users","method":"GET","target":"http://localhost:8081"}`,
+			stripPrefix: "/api/v1",
+		},
+		{
+			name:  "target with params",
+			route: `{"path":"/users/:id","method":"GET","target":"http://localhost:8081/users/:id"}`,
+		},
+		{
+			name:  "target with wildcard",
+			route: `{"path":"/files/*","method":"GET","target":"http://localhost:8081/static/*"}`,
+		},
+		{
+			name:        "query placeholders only",
+			route:       `{"path":"/search","method":"GET","target":"http://localhost:8081?q={query.q}"}`,
+			stripPrefix: "/api/v1",
+		},
+		{
+			name:  "redirect",
+			route: `{"path":"/old","method":"GET","redirect":{"to":"/api/v1/new"}}`,
+		},
+		{
+			name:  "mock",
+			route: `{"path":"/status","method":"GET","mock":{"body":{"ok":true}}}`,
+		},
+		{
+			name:        "own rewrite wins",
+			route:       `{"path":"/orders","method":"GET","target":"http://localhost:8081","stripPrefix":"/api"}`,
+			stripPrefix: "/api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, err := compileDomain(t, `{"basePath":"/api/v1","stripPrefix":"/api/v1","routes":[`+tt.route+`]}`)
+			if err != nil {
+				t.Fatalf("compile failed: %v", err)
+			}
+			if len(routes) != 1 {
+				t.Fatalf("compiled %d routes, want 1", len(routes))
+			}
+			if routes[0].StripPrefix != tt.stripPrefix {
+				t.Errorf("stripPrefix = %q, want %q", routes[0].StripPrefix, tt.stripPrefix)
+			}
+		})
+	}
+}
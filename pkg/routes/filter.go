@@ -0,0 +1,176 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing response filtering.
+// For reshaping responses, set the response block on routes in config/routes/ instead.
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+	"github.com/alramdein/kaimon/pkg/middleware"
+)
+
+// filterMaxBodyBytes caps the JSON bodies a response filter buffers
+const filterMaxBodyBytes = 10 << 20
+
+// responseFilter reshapes JSON response bodies: target, then allow/deny, then mapping, then group
+type responseFilter struct {
+	target  string
+	allow   [][]string
+	deny    [][]string
+	mapping map[string]string
+	group   string
+}
+
+// newResponseFilter creates a response filter from configuration
+func newResponseFilter(config *ResponseConfig) (*responseFilter, error) {
+	if len(config.Allow) > 0 && len(config.Deny) > 0 {
+		return nil, fmt.Errorf("response allow and deny are mutually exclusive")
+	}
+
+	f := &responseFilter{
+		target:  config.Target,
+		mapping: config.Mapping,
+		group:   config.Group,
+	}
+
+	for _, path := range config.Allow {
+		if path == "" || strings.Contains(path, "..") {
+			return nil, fmt.Errorf("invalid response allow path %q", path)
+		}
+		f.allow = append(f.allow, strings.Split(path, "."))
+	}
+	for _, path := range config.Deny {
+		if path == "" || strings.Contains(path, "..") {
+			return nil, fmt.Errorf("invalid response deny path %q", path)
+		}
+		f.deny = append(f.deny, strings.Split(path, "."))
+	}
+
+	return f, nil
+}
+
+// wrap captures the handler's successful JSON responses and rewrites them. Errors, streams
+// and other bodies are passed through as they are written.
+func (f *responseFilter) wrap(next framework.HandlerFunc) framework.HandlerFunc {
+	return func(ctx framework.Context) error {
+		filtered := func(status int, header http.Header) bool {
+			if streaming, _ := ctx.Get(middleware.ContextKeyStream).(bool); streaming {
+				return false
+			}
+			mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+			isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+			return status >= 200 && status < 300 && isJSON
+		}
+
+		capture, err := middleware.CaptureWhen(ctx, next, filtered, filterMaxBodyBytes)
+		if capture.Overflowed() {
+			return ctx.JSON(errResponseTooLarge.status, map[string]string{"error": errResponseTooLarge.message})
+		}
+		if !capture.Written() || capture.Bypassed() {
+			return err
+		}
+
+		var value interface{}
+		if json.Unmarshal(capture.Body(), &value) == nil {
+			if body, err := json.Marshal(f.apply(value)); err == nil {
+				capture.SetBody(body)
+			}
+		}
+
+		return capture.Send(ctx)
+	}
+}
+
+// apply reshapes a decoded JSON value
+func (f *responseFilter) apply(value interface{}) interface{} {
+	if f.target != "" {
+		target, ok := lookupJSON(value, f.target)
+		if !ok {
+			target = map[string]interface{}{}
+		}
+		value = target
+	}
+
+	if len(f.allow) > 0 {
+		value = allowFields(value, f.allow)
+	}
+	for _, path := range f.deny {
+		denyField(value, path)
+	}
+
+	if object, ok := value.(map[string]interface{}); ok {
+		for from, to := range f.mapping {
+			if field, exists := object[from]; exists {
+				delete(object, from)
+				object[to] = field
+			}
+		}
+	}
+
+	if f.group != "" {
+		value = map[string]interface{}{f.group: value}
+	}
+
+	return value
+}
+
+// allowFields keeps only the given paths; arrays apply the paths to every element
+func allowFields(value interface{}, paths [][]string) interface{} {
+	switch node := value.(type) {
+	case []interface{}:
+		kept := make([]interface{}, len(node))
+		for i, element := range node {
+			kept[i] = allowFields(element, paths)
+		}
+		return kept
+
+	case map[string]interface{}:
+		// Group the remaining path segments by their first key
+		children := make(map[string][][]string)
+		whole := make(map[string]bool)
+		for _, path := range paths {
+			if len(path) == 1 {
+				whole[path[0]] = true
+			} else {
+				children[path[0]] = append(children[path[0]], path[1:])
+			}
+		}
+
+		kept := make(map[string]interface{})
+		for key, child := range node {
+			switch {
+			case whole[key]:
+				kept[key] = child
+			case children[key] != nil:
+				kept[key] = allowFields(child, children[key])
+			}
+		}
+		return kept
+
+	default:
+		return value
+	}
+}
+
+// denyField removes a path; arrays apply it to every element
+func denyField(value interface{}, path []string) {
+	switch node := value.(type) {
+	case []interface{}:
+		for _, element := range node {
+			denyField(element, path)
+		}
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(node, path[0])
+			return
+		}
+		if child, ok := node[path[0]]; ok {
+			denyField(child, path[1:])
+		}
+	}
+}
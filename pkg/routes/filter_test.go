@@ -0,0 +1,110 @@
+package routes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseFilterPassesStreamsThrough(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(w, "data: last\n\n")
+	}))
+	t.Cleanup(upstream.Close)
+	t.Cleanup(func() { close(release) })
+
+	var routes []Route
+	for _, path := range []string{"/events", "/download"} {
+		routes = append(routes, Route{
+			Path:     path,
+			Method:   http.MethodGet,
+			Target:   upstream.URL + path,
+			Response: &ResponseConfig{Deny: []string{"secret"}},
+		})
+	}
+	srv := newTestGateway(t, routes)
+
+	for _, path := range []string{"/events", "/download"} {
+		t.Run(path, func(t *testing.T) {
+			resp, err := srv.Client().Get(srv.URL + path)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			// The upstream holds the rest of the body, so the first event must not be buffered
+			line := make(chan string, 1)
+			go func() {
+				text, _ := bufio.NewReader(resp.Body).ReadString('\n')
+				line <- text
+			}()
+			select {
+			case text := <-line:
+				if text != "data: first\n" {
+					t.Errorf("first line = %q, want %q", text, "data: first\n")
+				}
+			case <-time.After(time.Second):
+				t.Fatal("first event was buffered by the response filter")
+			}
+		})
+	}
+}
+
+func TestResponseFilterCapsBufferedBodies(t *testing.T) {
+	large := `{"items":"` + strings.Repeat("x", filterMaxBodyBytes) + `"}`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/large":
+			io.WriteString(w, large)
+		default:
+			io.WriteString(w, `{"id":1,"secret":"s"}`)
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	var routes []Route
+	for _, path := range []string{"/small", "/large"} {
+		routes = append(routes, Route{
+			Path:     path,
+			Method:   http.MethodGet,
+			Target:   upstream.URL + path,
+			Response: &ResponseConfig{Deny: []string{"secret"}},
+		})
+	}
+	srv := newTestGateway(t, routes)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{path: "/small", status: http.StatusOK, body: `{"id":1}`},
+		{path: "/large", status: http.StatusBadGateway, body: `{"error":"upstream response too large to filter"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+tt.path, nil)
+			status, body := send(t, srv.Client(), req)
+			if status != tt.status || strings.TrimSpace(body) != tt.body {
+				t.Errorf("got %d %s, want %d %s", status, body, tt.status, tt.body)
+			}
+		})
+	}
+}
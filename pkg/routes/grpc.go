@@ -0,0 +1,96 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing gRPC proxying.
+// For proxying gRPC services, set "protocol": "grpc" on routes in config/routes/ instead.
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// gRPC status codes (https://grpc.io/docs/guides/status-codes/)
+const (
+	grpcOK                 = 0
+	grpcCanceled           = 1
+	grpcUnknown            = 2
+	grpcInvalidArgument    = 3
+	grpcDeadlineExceeded   = 4
+	grpcNotFound           = 5
+	grpcAlreadyExists      = 6
+	grpcPermissionDenied   = 7
+	grpcResourceExhausted  = 8
+	grpcFailedPrecondition = 9
+	grpcAborted            = 10
+	grpcOutOfRange         = 11
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+	grpcDataLoss           = 15
+	grpcUnauthenticated    = 16
+)
+
+// upstreamProtocol returns the protocol spoken to the route's upstreams
+func (r Route) upstreamProtocol() string {
+	if r.Transcode != nil {
+		return ProtocolGRPC
+	}
+	return r.Protocol
+}
+
+// grpcStatus maps the HTTP status of a gateway error to a gRPC status code
+func grpcStatus(status int) int {
+	switch status {
+	case http.StatusGatewayTimeout:
+		return grpcDeadlineExceeded
+	case http.StatusRequestEntityTooLarge:
+		return grpcResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpcUnavailable
+	default:
+		return grpcInternal
+	}
+}
+
+// httpStatus maps a gRPC status code to the HTTP status returned to JSON clients
+func httpStatus(code int) int {
+	switch code {
+	case grpcOK:
+		return http.StatusOK
+	case grpcCanceled:
+		return 499
+	case grpcInvalidArgument, grpcFailedPrecondition, grpcOutOfRange:
+		return http.StatusBadRequest
+	case grpcDeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case grpcNotFound:
+		return http.StatusNotFound
+	case grpcAlreadyExists, grpcAborted:
+		return http.StatusConflict
+	case grpcPermissionDenied:
+		return http.StatusForbidden
+	case grpcResourceExhausted:
+		return http.StatusTooManyRequests
+	case grpcUnimplemented:
+		return http.StatusNotImplemented
+	case grpcUnavailable:
+		return http.StatusServiceUnavailable
+	case grpcUnauthenticated:
+		return http.StatusUnauthorized
+	default:
+		// grpcUnknown, grpcInternal, grpcDataLoss and unrecognised codes
+		return http.StatusInternalServerError
+	}
+}
+
+// failGRPC answers with a trailers-only gRPC response, since gRPC clients read the
+// outcome from grpc-status rather than from the HTTP status
+func failGRPC(ctx framework.Context, perr *proxyError) error {
+	res := ctx.Response()
+	res.Header().Set("Content-Type", "application/grpc")
+	res.Header().Set("Grpc-Status", strconv.Itoa(grpcStatus(perr.status)))
+	res.Header().Set("Grpc-Message", perr.message)
+	res.WriteHeader(http.StatusOK)
+	return nil
+}
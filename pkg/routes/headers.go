@@ -0,0 +1,253 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing proxy header handling.
+// For configuring forwarding headers, edit the forwarding block in config/ JSON files instead.
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// hopHeaders are hop-by-hop headers that must not be forwarded (RFC 7230, section 6.1)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopHeaders strips hop-by-hop headers, including those named in Connection
+func removeHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// removeRequestHopHeaders strips hop-by-hop request headers but keeps "TE: trailers",
+// which upstreams such as gRPC servers rely on
+func removeRequestHopHeaders(header http.Header) {
+	trailers := false
+	for _, value := range header.Values("Te") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "trailers") {
+				trailers = true
+			}
+		}
+	}
+
+	removeHopHeaders(header)
+
+	if trailers {
+		header.Set("Te", "trailers")
+	}
+}
+
+// forwarder adds X-Forwarded-*, Forwarded and Via headers to proxied requests
+type forwarder struct {
+	xForwarded bool
+	forwarded  bool
+	via        string
+	trusted    []netip.Prefix
+}
+
+// newForwarder creates a forwarder from configuration
+func newForwarder(config *ForwardingConfig) (*forwarder, error) {
+	f := &forwarder{xForwarded: true}
+	if config == nil {
+		return f, nil
+	}
+
+	if config.XForwarded != nil {
+		f.xForwarded = *config.XForwarded
+	}
+	if config.Forwarded != nil {
+		f.forwarded = *config.Forwarded
+	}
+	f.via = config.Via
+
+	for _, cidr := range config.TrustedProxies {
+		prefix, err := parsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+		f.trusted = append(f.trusted, prefix)
+	}
+
+	return f, nil
+}
+
+// parsePrefix parses a CIDR or a single IP address
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// isTrusted reports whether an address belongs to a trusted proxy
+func (f *forwarder) isTrusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, prefix := range f.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets forwarding headers on the upstream request. Values set by the client are
+// only kept when the immediate peer is a trusted proxy, so spoofed headers are dropped.
+func (f *forwarder) apply(out http.Header, in *http.Request) {
+	peer := remoteIP(in)
+	trusted := f.isTrusted(peer)
+
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+
+	if !trusted {
+		out.Del("X-Forwarded-For")
+		out.Del("X-Forwarded-Proto")
+		out.Del("X-Forwarded-Host")
+		out.Del("Forwarded")
+	}
+
+	if f.xForwarded {
+		if prior := out.Values("X-Forwarded-For"); len(prior) > 0 {
+			out.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+peer)
+		} else {
+			out.Set("X-Forwarded-For", peer)
+		}
+		if out.Get("X-Forwarded-Proto") == "" {
+			out.Set("X-Forwarded-Proto", proto)
+		}
+		if out.Get("X-Forwarded-Host") == "" {
+			out.Set("X-Forwarded-Host", in.Host)
+		}
+	} else {
+		out.Del("X-Forwarded-For")
+		out.Del("X-Forwarded-Proto")
+		out.Del("X-Forwarded-Host")
+	}
+
+	if f.forwarded {
+		element := fmt.Sprintf("for=%s;host=%s;proto=%s", forwardedNode(peer), quoteForwarded(in.Host), proto)
+		if prior := out.Values("Forwarded"); len(prior) > 0 {
+			out.Set("Forwarded", strings.Join(prior, ", ")+", "+element)
+		} else {
+			out.Set("Forwarded", element)
+		}
+	} else {
+		out.Del("Forwarded")
+	}
+
+	if f.via != "" {
+		addVia(out, in.ProtoMajor, in.ProtoMinor, f.via)
+	}
+}
+
+// applyResponse adds Via to the response sent back to the client
+func (f *forwarder) applyResponse(out http.Header, resp *http.Response) {
+	if f.via != "" {
+		addVia(out, resp.ProtoMajor, resp.ProtoMinor, f.via)
+	}
+}
+
+// addVia appends this gateway to the Via header (RFC 7230, section 5.7.1)
+func addVia(header http.Header, major, minor int, pseudonym string) {
+	version := fmt.Sprintf("%d.%d", major, minor)
+	if major >= 2 {
+		version = fmt.Sprintf("%d", major)
+	}
+
+	entry := version + " " + pseudonym
+	if prior := header.Values("Via"); len(prior) > 0 {
+		header.Set("Via", strings.Join(prior, ", ")+", "+entry)
+	} else {
+		header.Set("Via", entry)
+	}
+}
+
+// remoteIP returns the IP of the immediate peer
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// forwardedNode formats an address as a Forwarded node, quoting IPv6 (RFC 7239, section 6)
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// quoteForwarded quotes a Forwarded value when it isn't a plain token
+func quoteForwarded(value string) string {
+	if strings.ContainsAny(value, ":[]\" ,;=") {
+		return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+	}
+	return value
+}
+
+// mergeForwarding fills unset forwarding fields from the parent levels, nearest first
+func mergeForwarding(levels ...*ForwardingConfig) *ForwardingConfig {
+	var merged *ForwardingConfig
+
+	for _, level := range levels {
+		if level == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &ForwardingConfig{}
+		}
+		if merged.XForwarded == nil {
+			merged.XForwarded = level.XForwarded
+		}
+		if merged.Forwarded == nil {
+			merged.Forwarded = level.Forwarded
+		}
+		if merged.Via == "" {
+			merged.Via = level.Via
+		}
+		if merged.TrustedProxies == nil {
+			merged.TrustedProxies = level.TrustedProxies
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwarderApply(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name   string
+		config *ForwardingConfig
+		remote string
+		header map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "untrusted peer drops spoofed headers",
+			remote: "203.0.113.7:5000",
+			header: map[string]string{
+				"X-Forwarded-For":   "10.0.0.1",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "admin.internal",
+				"Forwarded":         "for=10.0.0.1",
+			},
+			want: map[string]string{
+				"X-Forwarded-For":   "203.0.113.7",
+				"X-Forwarded-Proto": "http",
+				"X-Forwarded-Host":  "api.example.com",
+				"Forwarded":         "",
+			},
+		},
+		{
+			name:   "peer outside the trusted range is untrusted",
+			config: &ForwardingConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remote: "203.0.113.7:5000",
+			header: map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https"},
+			want:   map[string]string{"X-Forwarded-For": "203.0.113.7", "X-Forwarded-Proto": "http"},
+		},
+		{
+			name:   "trusted range keeps and extends headers",
+			config: &ForwardingConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remote: "10.1.2.3:5000",
+			header: map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "www.example.com"},
+			want:   map[string]string{"X-Forwarded-For": "198.51.100.1, 10.1.2.3", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "www.example.com"},
+		},
+		{
+			name:   "trusted single address",
+			config: &ForwardingConfig{TrustedProxies: []string{"192.0.2.10"}},
+			remote: "192.0.2.10:5000",
+			header: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:   map[string]string{"X-Forwarded-For": "198.51.100.1, 192.0.2.10"},
+		},
+		{
+			name:   "IPv4-mapped peer matches an IPv4 range",
+			config: &ForwardingConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remote: "[::ffff:10.1.2.3]:5000",
+			header: map[string]string{"X-Forwarded-Proto": "https"},
+			want:   map[string]string{"X-Forwarded-Proto": "https"},
+		},
+		{
+			name:   "x-forwarded disabled",
+			config: &ForwardingConfig{XForwarded: &no, TrustedProxies: []string{"10.0.0.0/8"}},
+			remote: "10.1.2.3:5000",
+			header: map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:   map[string]string{"X-Forwarded-For": "", "X-Forwarded-Proto": "", "X-Forwarded-Host": ""},
+		},
+		{
+			name:   "forwarded from an untrusted peer",
+			config: &ForwardingConfig{Forwarded: &yes},
+			remote: "[2001:db8::1]:5000",
+			header: map[string]string{"Forwarded": "for=10.0.0.1"},
+			want:   map[string]string{"Forwarded": `for="[2001:db8::1]";host=api.example.com;proto=http`},
+		},
+		{
+			name:   "forwarded from a trusted peer",
+			config: &ForwardingConfig{Forwarded: &yes, TrustedProxies: []string{"10.0.0.0/8"}},
+			remote: "10.1.2.3:5000",
+			header: map[string]string{"Forwarded": "for=198.51.100.1"},
+			want:   map[string]string{"Forwarded": "for=198.51.100.1, for=10.1.2.3;host=api.example.com;proto=http"},
+		},
+		{
+			name:   "via",
+			config: &ForwardingConfig{Via: "kaimon"},
+			remote: "203.0.113.7:5000",
+			header: map[string]string{"Via": "1.1 edge"},
+			want:   map[string]string{"Via": "1.1 edge, 1.1 kaimon"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newForwarder(tt.config)
+			if err != nil {
+				t.Fatalf("newForwarder: %v", err)
+			}
+
+			in := httptest.NewRequest("GET", "http://api.example.com/users", nil)
+			in.RemoteAddr = tt.remote
+			out := http.Header{}
+			for name, value := range tt.header {
+				in.Header.Set(name, value)
+				out.Set(name, value)
+			}
+
+			f.apply(out, in)
+			for name, want := range tt.want {
+				if got := out.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewForwarderRejectsInvalidProxies(t *testing.T) {
+	for _, proxy := range []string{"10.0.0.0/33", "proxy.internal", ""} {
+		if _, err := newForwarder(&ForwardingConfig{TrustedProxies: []string{proxy}}); err == nil {
+			t.Errorf("trusted proxy %q was accepted", proxy)
+		}
+	}
+}
+
+func TestRemoveRequestHopHeaders(t *testing.T) {
+	header := http.Header{
+		"Connection":    {"keep-alive, X-Session"},
+		"X-Session":     {"abc"},
+		"Keep-Alive":    {"timeout=5"},
+		"Te":            {"trailers, gzip"},
+		"Upgrade":       {"h2c"},
+		"Authorization": {"Bearer token"},
+	}
+
+	removeRequestHopHeaders(header)
+
+	for _, name := range []string{"Connection", "X-Session", "Keep-Alive", "Upgrade"} {
+		if header.Get(name) != "" {
+			t.Errorf("%s was forwarded", name)
+		}
+	}
+	if got := header.Get("Te"); got != "trailers" {
+		t.Errorf("Te = %q, want trailers", got)
+	}
+	if header.Get("Authorization") == "" {
+		t.Errorf("end-to-end Authorization was dropped")
+	}
+}
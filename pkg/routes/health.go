@@ -0,0 +1,214 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing upstream health checking.
+// For configuring health checks, edit the healthCheck block in config/routes/ instead.
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default health check settings
+const (
+	defaultHealthInterval      = 10 * time.Second
+	defaultHealthTimeout       = 2 * time.Second
+	defaultHealthyThreshold    = 2
+	defaultUnhealthyThreshold  = 3
+	defaultPassiveMaxFailures  = 5
+	defaultPassiveEjectionTime = 30 * time.Second
+)
+
+// HealthChecker tracks upstream health from active probes and passive observations.
+// Targets sharing an origin and configuration share their health state across routes.
+type HealthChecker struct {
+	mu      sync.Mutex
+	targets map[string]*targetHealth
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewHealthChecker creates a new health checker
+func NewHealthChecker() *HealthChecker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HealthChecker{
+		targets: make(map[string]*targetHealth),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// register returns the health state for an upstream origin, starting active probes if configured
+func (h *HealthChecker) register(origin *url.URL, config *HealthCheckConfig, client *http.Client) *targetHealth {
+	fingerprint, _ := json.Marshal(config)
+	key := origin.Scheme + "://" + origin.Host + "|" + string(fingerprint)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if target, exists := h.targets[key]; exists {
+		return target
+	}
+
+	target := &targetHealth{
+		name:          origin.Scheme + "://" + origin.Host,
+		config:        config,
+		activeHealthy: true,
+	}
+	h.targets[key] = target
+
+	if config.Active != nil {
+		probeURL := origin.Scheme + "://" + origin.Host + "/" + strings.TrimPrefix(config.Active.Path, "/")
+		h.wg.Add(1)
+		go h.probe(target, probeURL, client)
+	}
+
+	return target
+}
+
+// Stop stops all active probes
+func (h *HealthChecker) Stop() {
+	h.cancel()
+	h.wg.Wait()
+}
+
+// probe runs active health checks for a target until the checker stops
+func (h *HealthChecker) probe(target *targetHealth, probeURL string, client *http.Client) {
+	defer h.wg.Done()
+
+	active := target.config.Active
+	ticker := time.NewTicker(durationOr(active.Interval, defaultHealthInterval))
+	defer ticker.Stop()
+
+	for {
+		target.observeProbe(h.check(probeURL, client, durationOr(active.Timeout, defaultHealthTimeout)))
+
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check performs a single probe; any 2xx or 3xx response counts as healthy
+func (h *HealthChecker) check(probeURL string, client *http.Client, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(h.ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+// targetHealth is the health state of a single upstream origin
+type targetHealth struct {
+	mu     sync.Mutex
+	name   string
+	config *HealthCheckConfig
+
+	// Active probe state
+	activeHealthy bool
+	probeStreak   int
+
+	// Passive observation state
+	failures     int
+	ejectedUntil time.Time
+}
+
+// available reports whether the target may receive traffic
+func (t *targetHealth) available() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.activeHealthy && !time.Now().Before(t.ejectedUntil)
+}
+
+// observeProbe records an active probe result
+func (t *targetHealth) observeProbe(healthy bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	active := t.config.Active
+
+	// probeStreak counts consecutive results that disagree with the current state
+	if healthy == t.activeHealthy {
+		t.probeStreak = 0
+		return
+	}
+	t.probeStreak++
+
+	threshold := intOr(active.UnhealthyThreshold, defaultUnhealthyThreshold)
+	if healthy {
+		threshold = intOr(active.HealthyThreshold, defaultHealthyThreshold)
+	}
+
+	if t.probeStreak >= threshold {
+		t.activeHealthy = healthy
+		t.probeStreak = 0
+		if healthy {
+			log.Printf("Upstream %s is healthy again", t.name)
+		} else {
+			log.Printf("Upstream %s failed %d health checks, marking unhealthy", t.name, threshold)
+		}
+	}
+}
+
+// observeResponse records the outcome of a proxied request for passive checks
+func (t *targetHealth) observeResponse(success bool) {
+	passive := t.config.Passive
+	if passive == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if success {
+		t.failures = 0
+		return
+	}
+
+	t.failures++
+	maxFailures := intOr(passive.MaxFailures, defaultPassiveMaxFailures)
+	if t.failures >= maxFailures {
+		ejection := durationOr(passive.EjectDuration, defaultPassiveEjectionTime)
+		t.ejectedUntil = time.Now().Add(ejection)
+		t.failures = 0
+		log.Printf("Upstream %s failed %d consecutive requests, ejecting for %v", t.name, maxFailures, ejection)
+	}
+}
+
+// availableUpstreams returns the upstreams currently eligible for selection
+func availableUpstreams(upstreams []*upstream) []*upstream {
+	candidates := make([]*upstream, 0, len(upstreams))
+	for _, u := range upstreams {
+		if u.health == nil || u.health.available() {
+			candidates = append(candidates, u)
+		}
+	}
+	return candidates
+}
+
+// intOr returns the configured value or a fallback when unset
+func intOr(value, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+	"github.com/alramdein/kaimon/pkg/middleware"
+)
+
+// newTestGateway loads routes into a fresh Echo framework and serves it
+func newTestGateway(t testing.TB, routes []Route) *httptest.Server {
+	t.Helper()
+	return newCompiledTestGateway(t, middleware.NewManager(), &CompiledRoutes{Routes: routes})
+}
+
+// newCompiledTestGateway loads a compiled configuration with the given middlewares and serves it
+func newCompiledTestGateway(t testing.TB, manager *middleware.Manager, compiled *CompiledRoutes) *httptest.Server {
+	t.Helper()
+
+	fw := framework.NewEchoFramework()
+	loader := NewLoader(fw.Router(), manager)
+	if err := loader.Load(compiled); err != nil {
+		t.Fatalf("failed to load routes: %v", err)
+	}
+	t.Cleanup(loader.Close)
+
+	srv := httptest.NewServer(fw.(http.Handler))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newNamedUpstream answers every request with its name
+func newNamedUpstream(t testing.TB, name string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, name)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// send performs a request and returns the status and body
+func send(t testing.TB, client *http.Client, req *http.Request) (int, string) {
+	t.Helper()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// testContext is a framework context that only carries a request, path parameters
+// and values, for unit tests of code that reads them
+type testContext struct {
+	framework.Context
+	req    *http.Request
+	params map[string]string
+	values map[string]interface{}
+}
+
+// newTestContext creates a context for req
+func newTestContext(req *http.Request) *testContext {
+	return &testContext{req: req, params: make(map[string]string), values: make(map[string]interface{})}
+}
+
+func (c *testContext) Request() *http.Request            { return c.req }
+func (c *testContext) SetRequest(req *http.Request)      { c.req = req }
+func (c *testContext) Param(key string) string           { return c.params[key] }
+func (c *testContext) Set(key string, value interface{}) { c.values[key] = value }
+func (c *testContext) Get(key string) interface{}        { return c.values[key] }
+func (c *testContext) QueryParam(key string) string      { return c.req.URL.Query().Get(key) }
@@ -6,7 +6,6 @@ package routes
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -20,6 +19,10 @@ import (
 type Loader struct {
 	router            framework.Router
 	middlewareManager *middleware.Manager
+	transports        *TransportRegistry
+	health            *HealthChecker
+	breakers          *BreakerRegistry
+	mirrorSlots       chan struct{}
 }
 
 // NewLoader creates a new route loader
@@ -27,9 +30,19 @@ func NewLoader(router framework.Router, middlewareManager *middleware.Manager) *
 	return &Loader{
 		router:            router,
 		middlewareManager: middlewareManager,
+		transports:        NewTransportRegistry(),
+		health:            NewHealthChecker(),
+		breakers:          NewBreakerRegistry(),
+		mirrorSlots:       make(chan struct{}, maxMirrorConcurrency),
 	}
 }
 
+// Close stops health checks and releases idle upstream connections
+func (l *Loader) Close() {
+	l.health.Stop()
+	l.transports.Close()
+}
+
 // LoadFromFile loads routes from a compiled routes file
 func (l *Loader) LoadFromFile(filePath string) error {
 	data, err := os.ReadFile(filePath)
@@ -47,6 +60,58 @@ func (l *Loader) LoadFromFile(filePath string) error {
 
 // Load loads routes from compiled configuration
 func (l *Loader) Load(compiled *CompiledRoutes) error {
+	// Routes sharing a method and path are dispatched on their hosts and match conditions
+	table := newRouteTable()
+	splits := false
+
+	// Register routes
+	for _, route := range compiled.Routes {
+		matcher, err := newRouteMatcher(route)
+		if err != nil {
+			return fmt.Errorf("invalid route %s %s: %w", route.Method, route.Path, err)
+		}
+
+		handler, err := l.createProxyHandler(route)
+		if err != nil {
+			return fmt.Errorf("failed to create handler for %s %s: %w", route.Method, route.Path, err)
+		}
+
+		// Wrap with route-specific middlewares
+		handler = l.wrapMiddlewares(handler, route.Middlewares)
+
+		// Pick the split variant before route middlewares run, so they can tag it
+		var selector *splitSelector
+		if len(route.Split) > 0 {
+			selector, err = newSplitSelector(route)
+			if err != nil {
+				return fmt.Errorf("failed to create handler for %s %s: %w", route.Method, route.Path, err)
+			}
+			handler = selector.wrap(handler)
+			splits = true
+		}
+
+		// Send plain HTTP requests to HTTPS before anything else runs
+		if route.HTTPSRedirect {
+			forwarder, err := newForwarder(route.Forwarding)
+			if err != nil {
+				return fmt.Errorf("failed to create handler for %s %s: %w", route.Method, route.Path, err)
+			}
+			handler = redirectToHTTPS(handler, forwarder)
+		}
+
+		method := strings.ToUpper(route.Method)
+		if !isSupportedMethod(method) {
+			return fmt.Errorf("unsupported method: %s", route.Method)
+		}
+
+		table.add(method, routerPath(route.Path), dispatchCandidate{matcher: matcher, handler: handler, selector: selector})
+	}
+
+	// Pick split variants ahead of the global middlewares, so they can tag them too
+	if splits {
+		l.router.Use(table.selectVariant)
+	}
+
 	// Apply global middlewares
 	if compiled.Middlewares != nil {
 		globalMiddlewares := make([]framework.MiddlewareFunc, 0)
@@ -68,117 +133,257 @@ func (l *Loader) Load(compiled *CompiledRoutes) error {
 		}
 	}
 
-	// Register routes
-	for _, route := range compiled.Routes {
-		handler := l.createProxyHandler(route)
+	// Register admin endpoints
+	if compiled.Admin != nil && compiled.Admin.Path != "" {
+		l.router.GET(strings.TrimSuffix(compiled.Admin.Path, "/")+"/circuit-breakers", func(ctx framework.Context) error {
+			return ctx.JSON(http.StatusOK, l.breakers.Statuses())
+		})
+	}
 
-		// Wrap with route-specific middlewares
-		if route.Middlewares != nil {
-			routeMiddlewares := make([]framework.MiddlewareFunc, 0)
+	table.register(l.router)
 
-			// Add route onRequest middlewares
-			if len(route.Middlewares.OnRequest) > 0 {
-				onRequestMws := l.middlewareManager.GetMiddlewares(route.Middlewares.OnRequest)
-				routeMiddlewares = append(routeMiddlewares, onRequestMws...)
-			}
+	return nil
+}
 
-			// Add route onResponse middlewares
-			if len(route.Middlewares.OnResponse) > 0 {
-				onResponseMws := l.middlewareManager.GetMiddlewares(route.Middlewares.OnResponse)
-				routeMiddlewares = append(routeMiddlewares, onResponseMws...)
-			}
+// wrapMiddlewares wraps a handler with the onRequest then onResponse middlewares of a config
+func (l *Loader) wrapMiddlewares(handler framework.HandlerFunc, config *MiddlewareConfig) framework.HandlerFunc {
+	if config == nil {
+		return handler
+	}
 
-			// Apply middlewares in reverse order
-			for i := len(routeMiddlewares) - 1; i >= 0; i-- {
-				handler = routeMiddlewares[i](handler)
-			}
-		}
+	routeMiddlewares := make([]framework.MiddlewareFunc, 0)
 
-		// Register based on method
-		switch strings.ToUpper(route.Method) {
-		case "GET":
-			l.router.GET(route.Path, handler)
-		case "POST":
-			l.router.POST(route.Path, handler)
-		case "PUT":
-			l.router.PUT(route.Path, handler)
-		case "DELETE":
-			l.router.DELETE(route.Path, handler)
-		case "PATCH":
-			l.router.PATCH(route.Path, handler)
-		default:
-			return fmt.Errorf("unsupported method: %s", route.Method)
-		}
+	// Add route onRequest middlewares
+	if len(config.OnRequest) > 0 {
+		onRequestMws := l.middlewareManager.GetMiddlewares(config.OnRequest)
+		routeMiddlewares = append(routeMiddlewares, onRequestMws...)
 	}
 
-	return nil
+	// Add route onResponse middlewares
+	if len(config.OnResponse) > 0 {
+		onResponseMws := l.middlewareManager.GetMiddlewares(config.OnResponse)
+		routeMiddlewares = append(routeMiddlewares, onResponseMws...)
+	}
+
+	// Apply middlewares in reverse order
+	for i := len(routeMiddlewares) - 1; i >= 0; i-- {
+		handler = routeMiddlewares[i](handler)
+	}
+
+	return handler
 }
 
 // createProxyHandler creates a proxy handler for the route
-func (l *Loader) createProxyHandler(route Route) framework.HandlerFunc {
-	return func(ctx framework.Context) error {
-		// Parse target URL
-		targetURL, err := url.Parse(route.Target)
+func (l *Loader) createProxyHandler(route Route) (framework.HandlerFunc, error) {
+	if len(route.Split) > 0 {
+		return l.createSplitHandler(route)
+	}
+
+	var handler framework.HandlerFunc
+	if len(route.Backends) > 0 {
+		aggregate, err := l.createAggregateHandler(route)
+		if err != nil {
+			return nil, err
+		}
+		handler = aggregate
+	} else if route.Redirect != nil {
+		r, err := newRedirect(route)
+		if err != nil {
+			return nil, err
+		}
+		handler = r.handle
+	} else if route.Mock != nil {
+		mock, err := newMockResponse(route.Mock)
+		if err != nil {
+			return nil, err
+		}
+		handler = mock.handle
+	} else {
+		p, err := l.newProxy(route)
 		if err != nil {
-			return ctx.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "invalid target URL",
-			})
+			return nil, err
 		}
+		handler = p.handle
+	}
 
-		// Create proxy request
-		req := ctx.Request()
-		proxyURL := targetURL.Scheme + "://" + targetURL.Host + targetURL.Path
-		if req.URL.RawQuery != "" {
-			proxyURL += "?" + req.URL.RawQuery
+	// Shadow the request as it is sent upstream, after any transformation
+	if route.Mirror != nil {
+		m, err := newMirror(route, l.mirrorSlots, l.transports)
+		if err != nil {
+			return nil, err
 		}
+		handler = m.wrap(handler)
+	}
 
-		proxyReq, err := http.NewRequest(req.Method, proxyURL, req.Body)
+	// Reshape request bodies after middlewares have run, so set values see their headers
+	if route.Request != nil {
+		transformer, err := newRequestTransformer(route.Request, route.MaxBodyBytes)
 		if err != nil {
-			return ctx.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "failed to create proxy request",
-			})
+			return nil, err
 		}
+		handler = transformer.wrap(handler)
+	}
 
-		// Copy headers
-		for key, values := range req.Header {
-			for _, value := range values {
-				proxyReq.Header.Add(key, value)
-			}
+	// Reshape response bodies closest to the upstream, inside any middleware
+	if route.Response != nil {
+		filter, err := newResponseFilter(route.Response)
+		if err != nil {
+			return nil, err
 		}
+		handler = filter.wrap(handler)
+	}
 
-		// Add custom headers from route config
-		for key, value := range route.Headers {
-			proxyReq.Header.Set(key, value)
+	// Expose the catch-all of a :name* route under its name
+	if name := wildcardParam(route.Path); name != "" {
+		next := handler
+		handler = func(ctx framework.Context) error {
+			return next(&wildcardContext{Context: ctx, name: name})
 		}
+	}
+
+	return handler, nil
+}
+
+// wildcardContext answers Param for a :name* route's name with the router's * parameter
+type wildcardContext struct {
+	framework.Context
+	name string
+}
+
+// Param returns a path parameter
+func (c *wildcardContext) Param(key string) string {
+	if key == c.name {
+		return c.Context.Param("*")
+	}
+	return c.Context.Param(key)
+}
+
+// createSplitHandler creates a handler that sends each request to one variant of the route
+func (l *Loader) createSplitHandler(route Route) (framework.HandlerFunc, error) {
+	selector, err := newSplitSelector(route)
+	if err != nil {
+		return nil, err
+	}
 
-		// Execute proxy request
-		client := &http.Client{}
-		resp, err := client.Do(proxyReq)
+	h := &splitHandler{
+		selector: selector,
+		handlers: make(map[string]framework.HandlerFunc, len(route.Split)),
+	}
+	for i, sub := range splitRoutes(route) {
+		handler, err := l.createProxyHandler(sub)
 		if err != nil {
-			return ctx.JSON(http.StatusBadGateway, map[string]string{
-				"error": "failed to proxy request",
-			})
+			return nil, fmt.Errorf("variant %s: %w", selector.names[i], err)
 		}
-		defer resp.Body.Close()
+		h.handlers[selector.names[i]] = l.wrapMiddlewares(handler, route.Split[i].Middlewares)
+	}
 
-		// Copy response headers
-		for key, values := range resp.Header {
-			for _, value := range values {
-				ctx.Response().Header().Add(key, value)
-			}
+	return h.handle, nil
+}
+
+// createAggregateHandler creates a handler that merges the responses of the route's backends
+func (l *Loader) createAggregateHandler(route Route) (framework.HandlerFunc, error) {
+	a := &aggregator{
+		route:   route,
+		partial: route.FailurePolicy == FailurePolicyPartial,
+	}
+
+	for _, sub := range backendRoutes(route) {
+		p, err := l.newProxy(sub)
+		if err != nil {
+			return nil, err
 		}
+		a.backends = append(a.backends, p)
+	}
+
+	return a.handle, nil
+}
 
-		// Copy response body
-		body, err := io.ReadAll(resp.Body)
+// newProxy builds the proxy for a single route and registers its upstreams
+func (l *Loader) newProxy(route Route) (*proxy, error) {
+	targets := routeTargets(route)
+
+	// Transcoded routes call the gRPC method path on each target
+	var tc *transcoder
+	if route.Transcode != nil {
+		var err error
+		if tc, err = newTranscoder(route); err != nil {
+			return nil, err
+		}
+		for i := range targets {
+			targets[i].URL = strings.TrimSuffix(targets[i].URL, "/") + tc.path()
+		}
+	}
+
+	upstreams, err := newUpstreams(targets)
+	if err != nil {
+		return nil, err
+	}
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("route has no target")
+	}
+	if name := wildcardParam(route.Path); name != "" {
+		for _, u := range upstreams {
+			u.target.BindWildcard(name)
+		}
+	}
+
+	lb, err := newBalancer(route.LoadBalancing)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve clients, and track upstream health and circuit state when configured
+	for _, u := range upstreams {
+		origin, err := url.Parse(u.url)
+		if err != nil || origin.Host == "" || strings.Contains(origin.Host, "{") {
+			// Targets with a templated host can't be tracked per origin
+			continue
+		}
+
+		// A static origin resolves its client once instead of on every request
+		client, err := l.transports.Client(origin, route.upstreamProtocol(), route.Transport)
 		if err != nil {
-			return ctx.JSON(http.StatusInternalServerError, map[string]string{
-				"error": "failed to read response",
-			})
+			return nil, err
 		}
+		u.client = client
 
-		ctx.Response().WriteHeader(resp.StatusCode)
-		ctx.Response().Write(body)
+		if route.HealthCheck != nil {
+			u.health = l.health.register(origin, route.HealthCheck, client)
+		}
 
-		return nil
+		if route.CircuitBreaker != nil {
+			u.breaker = l.breakers.register(origin, route.CircuitBreaker)
+		}
+	}
+
+	retry, err := newRetryPolicy(route.Retry)
+	if err != nil {
+		return nil, err
+	}
+
+	forwarder, err := newForwarder(route.Forwarding)
+	if err != nil {
+		return nil, err
 	}
+
+	// Rewritten routes derive the upstream path from the request path
+	var rw *pathRewriter
+	if route.rewritesPath() {
+		if rw, err = newPathRewriter(route); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &proxy{
+		route:      route,
+		upstreams:  upstreams,
+		balancer:   lb,
+		retry:      retry,
+		forwarder:  forwarder,
+		transcoder: tc,
+		rewriter:   rw,
+		transports: l.transports,
+	}
+
+	return p, nil
 }
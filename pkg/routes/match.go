@@ -0,0 +1,320 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing host and header route matching.
+// For matching requests, set hosts on domains or match on routes in config/routes/ instead.
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+	"github.com/alramdein/kaimon/pkg/middleware"
+)
+
+// matchAny matches any value of a header, query parameter or cookie that is present
+const matchAny = "*"
+
+// routeMatcher checks a request against a route's hosts and match conditions
+type routeMatcher struct {
+	hosts []string
+	match *MatchConfig
+}
+
+// newRouteMatcher creates a matcher from the route's hosts and match conditions
+func newRouteMatcher(route Route) (*routeMatcher, error) {
+	m := &routeMatcher{match: route.Match}
+
+	for _, host := range route.Hosts {
+		host = strings.ToLower(host)
+		pattern := strings.TrimPrefix(host, "*.")
+		if pattern == "" || strings.ContainsAny(pattern, "*:/") {
+			return nil, fmt.Errorf("invalid host %q: use a name such as api.example.com or *.internal", host)
+		}
+		m.hosts = append(m.hosts, host)
+	}
+
+	if route.Match != nil {
+		for _, conditions := range []map[string]string{route.Match.Headers, route.Match.Query, route.Match.Cookies} {
+			for name := range conditions {
+				if name == "" {
+					return nil, fmt.Errorf("match condition needs a name")
+				}
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// conditional reports whether the matcher can reject a request
+func (m *routeMatcher) conditional() bool {
+	return len(m.hosts) > 0 || m.conditions() > 0
+}
+
+// conditions counts the header, query and cookie conditions
+func (m *routeMatcher) conditions() int {
+	if m.match == nil {
+		return 0
+	}
+	return len(m.match.Headers) + len(m.match.Query) + len(m.match.Cookies)
+}
+
+// specificity ranks matchers so exact hosts beat wildcards, which beat any host,
+// and more conditions beat fewer
+func (m *routeMatcher) specificity() int {
+	rank := 0
+	for _, host := range m.hosts {
+		if strings.HasPrefix(host, "*.") {
+			rank = max(rank, 1)
+		} else {
+			rank = 2
+		}
+	}
+	return rank<<16 + m.conditions()
+}
+
+// matches reports whether the request satisfies every condition
+func (m *routeMatcher) matches(req *http.Request) bool {
+	if len(m.hosts) > 0 && !m.matchesHost(req.Host) {
+		return false
+	}
+	if m.match == nil {
+		return true
+	}
+
+	for name, want := range m.match.Headers {
+		if !matchValue(req.Header.Values(name), want) {
+			return false
+		}
+	}
+
+	query := req.URL.Query()
+	for name, want := range m.match.Query {
+		if !matchValue(query[name], want) {
+			return false
+		}
+	}
+
+	for name, want := range m.match.Cookies {
+		cookie, err := req.Cookie(name)
+		if err != nil || (want != matchAny && cookie.Value != want) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesHost reports whether the request host, without its port, is one of the hosts
+func (m *routeMatcher) matchesHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, pattern := range m.hosts {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue reports whether any of the values equals want, or any value exists for *
+func matchValue(values []string, want string) bool {
+	for _, value := range values {
+		if want == matchAny || value == want {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatcher serves the routes that share a method and path, running the first
+// route whose hosts and match conditions accept the request
+type dispatcher struct {
+	path       string
+	candidates []dispatchCandidate
+}
+
+// dispatchCandidate is one route of a dispatcher
+type dispatchCandidate struct {
+	matcher *routeMatcher
+	handler framework.HandlerFunc
+	// selector picks the variant of a split route
+	selector *splitSelector
+}
+
+// add appends a route, keeping the most specific routes first
+func (d *dispatcher) add(c dispatchCandidate) {
+	d.candidates = append(d.candidates, c)
+	sort.SliceStable(d.candidates, func(i, j int) bool {
+		return d.candidates[i].matcher.specificity() > d.candidates[j].matcher.specificity()
+	})
+}
+
+// conditional reports whether any route of the dispatcher can reject a request
+func (d *dispatcher) conditional() bool {
+	for _, c := range d.candidates {
+		if c.matcher.conditional() {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the first route that accepts the request, or nil. Without conditions
+// the last of several identical routes wins, as it did when each was registered.
+func (d *dispatcher) match(req *http.Request) *dispatchCandidate {
+	if !d.conditional() {
+		return &d.candidates[len(d.candidates)-1]
+	}
+	for i := range d.candidates {
+		if d.candidates[i].matcher.matches(req) {
+			return &d.candidates[i]
+		}
+	}
+	return nil
+}
+
+// routeTable holds the dispatchers of every method and path. The router picks the most
+// specific path, so when none of its routes accepts the request, the table falls back
+// to the other paths that match, e.g. another host's catch-all.
+type routeTable struct {
+	dispatchers map[string]*dispatcher
+	order       []string
+	// paths lists the dispatchers of each method, most specific path first
+	paths map[string][]*dispatcher
+}
+
+// newRouteTable creates an empty route table
+func newRouteTable() *routeTable {
+	return &routeTable{
+		dispatchers: make(map[string]*dispatcher),
+		paths:       make(map[string][]*dispatcher),
+	}
+}
+
+// add adds a route under its method and router path
+func (t *routeTable) add(method, path string, c dispatchCandidate) {
+	key := method + " " + path
+	d := t.dispatchers[key]
+	if d == nil {
+		d = &dispatcher{path: path}
+		t.dispatchers[key] = d
+		t.order = append(t.order, key)
+		t.paths[method] = append(t.paths[method], d)
+		sort.SliceStable(t.paths[method], func(i, j int) bool {
+			return lessPath(t.paths[method][i].path, t.paths[method][j].path)
+		})
+	}
+	d.add(c)
+}
+
+// register registers each method and path once; unconditional routes skip the table
+func (t *routeTable) register(router framework.Router) {
+	for _, key := range t.order {
+		method, _, _ := strings.Cut(key, " ")
+		d := t.dispatchers[key]
+		if !d.conditional() {
+			router.Handle(method, d.path, d.match(nil).handler)
+			continue
+		}
+		router.Handle(method, d.path, func(ctx framework.Context) error {
+			c, ctx := t.resolve(d, ctx)
+			if c == nil {
+				return ctx.JSON(http.StatusNotFound, map[string]string{"error": "no route matches the request"})
+			}
+			return c.handler(ctx)
+		})
+	}
+}
+
+// selectVariant picks the variant of a split route before the global middlewares run,
+// so they can tag it
+func (t *routeTable) selectVariant(next framework.HandlerFunc) framework.HandlerFunc {
+	return func(ctx framework.Context) error {
+		if d := t.dispatchers[ctx.Request().Method+" "+ctx.Path()]; d != nil {
+			if c, routeCtx := t.resolve(d, ctx); c != nil && c.selector != nil {
+				ctx.Set(middleware.ContextKeyVariant, c.selector.pick(routeCtx))
+			}
+		}
+		return next(ctx)
+	}
+}
+
+// resolve returns the route serving a request the router sent to d, and the context
+// to run it with, or nil when no route accepts the request
+func (t *routeTable) resolve(d *dispatcher, ctx framework.Context) (*dispatchCandidate, framework.Context) {
+	req := ctx.Request()
+	if c := d.match(req); c != nil {
+		return c, ctx
+	}
+
+	path := req.URL.RawPath
+	if path == "" {
+		path = req.URL.Path
+	}
+	for _, other := range t.paths[req.Method] {
+		if other == d {
+			continue
+		}
+		params, ok := matchPath(other.path, path)
+		if !ok {
+			continue
+		}
+		if c := other.match(req); c != nil {
+			return c, &paramContext{Context: ctx, params: params}
+		}
+	}
+	return nil, ctx
+}
+
+// matchPath matches a request path against a router path, returning its parameters
+func matchPath(pattern, path string) (map[string]string, bool) {
+	patternSegments := strings.Split(pattern, "/")
+	pathSegments := strings.Split(path, "/")
+	params := make(map[string]string)
+
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			if i >= len(pathSegments) {
+				return nil, false
+			}
+			params["*"] = strings.Join(pathSegments[i:], "/")
+			return params, true
+		}
+		if i >= len(pathSegments) {
+			return nil, false
+		}
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+			params[name] = pathSegments[i]
+		} else if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+
+	return params, len(patternSegments) == len(pathSegments)
+}
+
+// paramContext answers Param with the parameters of a fallback route, which the
+// router did not match
+type paramContext struct {
+	framework.Context
+	params map[string]string
+}
+
+// Param returns a path parameter
+func (c *paramContext) Param(key string) string {
+	return c.params[key]
+}
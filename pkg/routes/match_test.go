@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostFallback(t *testing.T) {
+	a := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "a:"+r.URL.Path)
+	}))
+	t.Cleanup(a.Close)
+	b := newNamedUpstream(t, "b")
+	gw := newTestGateway(t, []Route{
+		{Path: "/*", Method: "GET", Target: a.URL + "/*", Hosts: []string{"a.com"}},
+		{Path: "/api/x", Method: "GET", Target: b.URL, Hosts: []string{"b.com"}},
+	})
+
+	tests := []struct {
+		host   string
+		path   string
+		status int
+		body   string
+	}{
+		{host: "b.com", path: "/api/x", status: http.StatusOK, body: "b"},
+		{host: "a.com", path: "/api/x", status: http.StatusOK, body: "a:/api/x"},
+		{host: "a.com", path: "/other", status: http.StatusOK, body: "a:/other"},
+		{host: "c.com", path: "/api/x", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", gw.URL+tt.path, nil)
+			req.Host = tt.host
+			status, body := send(t, gw.Client(), req)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", status, tt.status, body)
+			}
+			if tt.body != "" && body != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		ok      bool
+		params  map[string]string
+	}{
+		{pattern: "/users", path: "/users", ok: true, params: map[string]string{}},
+		{pattern: "/users", path: "/users/1", ok: false},
+		{pattern: "/users/:id", path: "/users/1", ok: true, params: map[string]string{"id": "1"}},
+		{pattern: "/users/:id", path: "/users/", ok: false},
+		{pattern: "/users/:id/orders", path: "/users/1/orders", ok: true, params: map[string]string{"id": "1"}},
+		{pattern: "/*", path: "/api/x", ok: true, params: map[string]string{"*": "api/x"}},
+		{pattern: "/files/*", path: "/files/a/b", ok: true, params: map[string]string{"*": "a/b"}},
+		{pattern: "/files/*", path: "/files", ok: false},
+		{pattern: "/files/*", path: "/docs/a", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			params, ok := matchPath(tt.pattern, tt.path)
+			if ok != tt.ok {
+				t.Fatalf("ok = %t, want %t", ok, tt.ok)
+			}
+			for name, want := range tt.params {
+				if params[name] != want {
+					t.Errorf("param %s = %q, want %q", name, params[name], want)
+				}
+			}
+			if ok && len(params) != len(tt.params) {
+				t.Errorf("params = %v, want %v", params, tt.params)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing traffic mirroring.
+// For shadowing traffic, set "mirror" on routes in config/routes/ instead.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// maxMirrorConcurrency caps the shadow requests in flight across all routes
+const maxMirrorConcurrency = 64
+
+// maxMirrorBodyBytes caps the request body copied to the shadow; larger requests aren't mirrored
+const maxMirrorBodyBytes = 1 << 20
+
+// defaultMirrorTimeout bounds a shadow request when the route has no timeout
+const defaultMirrorTimeout = 30 * time.Second
+
+// mirror duplicates a share of a route's requests to a shadow upstream and discards the answers
+type mirror struct {
+	route      Route
+	target     *TargetTemplate
+	percentage float64
+	slots      chan struct{}
+	transports *TransportRegistry
+}
+
+// newMirror creates a mirror from the route configuration, sharing the gateway's mirror slots
+func newMirror(route Route, slots chan struct{}, transports *TransportRegistry) (*mirror, error) {
+	config := route.Mirror
+	if config.Target == "" {
+		return nil, fmt.Errorf("mirror requires a target")
+	}
+	if config.Percentage < 0 || config.Percentage > 100 {
+		return nil, fmt.Errorf("mirror percentage must be between 0 and 100")
+	}
+
+	target, err := ParseTargetTemplate(config.Target)
+	if err != nil {
+		return nil, err
+	}
+	if err := target.Validate(route.Path); err != nil {
+		return nil, err
+	}
+	if name := wildcardParam(route.Path); name != "" {
+		target.BindWildcard(name)
+	}
+
+	percentage := config.Percentage
+	if percentage == 0 {
+		percentage = 100
+	}
+
+	return &mirror{
+		route:      route,
+		target:     target,
+		percentage: percentage,
+		slots:      slots,
+		transports: transports,
+	}, nil
+}
+
+// wrap sends a copy of sampled requests to the shadow upstream alongside next. The body
+// is copied as the primary request streams it, and the shadow request starts once the
+// body has been read in full, so it never delays or fails the client's.
+func (m *mirror) wrap(next framework.HandlerFunc) framework.HandlerFunc {
+	return func(ctx framework.Context) error {
+		req := ctx.Request()
+		if upgradeType(req.Header) != "" || rand.Float64()*100 >= m.percentage {
+			return next(ctx)
+		}
+
+		// Drop the copy rather than queue it when the shadow can't keep up
+		select {
+		case m.slots <- struct{}{}:
+		default:
+			return next(ctx)
+		}
+
+		shadow, err := m.request(ctx)
+		if err != nil {
+			<-m.slots
+			log.Printf("Mirror of %s %s skipped: %v", req.Method, req.URL.Path, err)
+			return next(ctx)
+		}
+
+		if req.ContentLength == 0 {
+			go m.send(shadow, nil)
+			return next(ctx)
+		}
+
+		body := &mirrorBody{
+			ReadCloser: req.Body,
+			pending:    true,
+			send:       func(body []byte) { go m.send(shadow, body) },
+			skip:       func() { <-m.slots },
+		}
+		req.Body = body
+		defer body.finish()
+		return next(ctx)
+	}
+}
+
+// request builds the shadow request without its body, which is attached once read
+func (m *mirror) request(ctx framework.Context) (*http.Request, error) {
+	req := ctx.Request()
+
+	targetURL, err := url.Parse(m.target.Render(ctx))
+	if err != nil {
+		return nil, errInvalidTarget
+	}
+	if req.URL.RawQuery != "" {
+		if targetURL.RawQuery != "" {
+			targetURL.RawQuery += "&" + req.URL.RawQuery
+		} else {
+			targetURL.RawQuery = req.URL.RawQuery
+		}
+	}
+
+	shadow, err := http.NewRequest(req.Method, targetURL.String(), nil)
+	if err != nil {
+		return nil, errInvalidTarget
+	}
+
+	shadow.Header = req.Header.Clone()
+	removeRequestHopHeaders(shadow.Header)
+	for key, value := range m.route.Headers {
+		shadow.Header.Set(key, value)
+	}
+
+	return shadow, nil
+}
+
+// send performs the shadow request and discards the response. It outlives the client's
+// request, so it gets its own context.
+func (m *mirror) send(shadow *http.Request, body []byte) {
+	defer func() { <-m.slots }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), durationOr(m.route.Timeout, defaultMirrorTimeout))
+	defer cancel()
+	shadow = shadow.WithContext(ctx)
+	if len(body) > 0 {
+		shadow.Body = io.NopCloser(bytes.NewReader(body))
+		shadow.ContentLength = int64(len(body))
+	}
+
+	client, err := m.transports.Client(shadow.URL, ProtocolHTTP, m.route.Transport)
+	if err != nil {
+		log.Printf("Mirror to %s failed: %v", shadow.URL.Host, err)
+		return
+	}
+
+	resp, err := client.Do(shadow)
+	if err != nil {
+		log.Printf("Mirror to %s failed: %v", shadow.URL.Host, err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+}
+
+// mirrorBody copies a request body, up to maxMirrorBodyBytes, as the primary request
+// reads it. Reaching the end calls send with the copy; a larger body, a read error or
+// a body left unread when the primary request is done calls skip instead.
+type mirrorBody struct {
+	io.ReadCloser
+	send func(body []byte)
+	skip func()
+
+	// The upstream transport may read the body on its own goroutine
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	pending bool
+}
+
+// Read reads from the body, copying what it reads while the copy is pending
+func (b *mirrorBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.pending {
+		return n, err
+	}
+
+	if b.buf.Len()+n > maxMirrorBodyBytes || (err != nil && err != io.EOF) {
+		b.pending = false
+		b.buf = bytes.Buffer{}
+		b.skip()
+		return n, err
+	}
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.pending = false
+		b.send(b.buf.Bytes())
+	}
+	return n, err
+}
+
+// finish gives up on a copy whose body was not read to the end
+func (b *mirrorBody) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending {
+		b.pending = false
+		b.skip()
+	}
+}
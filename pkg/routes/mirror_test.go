@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMirrorBody(t *testing.T) {
+	errBroken := errors.New("connection broken")
+
+	tests := []struct {
+		name string
+		body io.Reader
+		// read is how many bytes the primary request reads, -1 for all of it
+		read int
+		sent string
+		skip bool
+	}{
+		{name: "read in full", body: strings.NewReader("payload"), read: -1, sent: "payload"},
+		{name: "empty body", body: strings.NewReader(""), read: -1, sent: ""},
+		{name: "at the cap", body: bytes.NewReader(make([]byte, maxMirrorBodyBytes)), read: -1, sent: string(make([]byte, maxMirrorBodyBytes))},
+		{name: "over the cap", body: bytes.NewReader(make([]byte, maxMirrorBodyBytes+1)), read: -1, skip: true},
+		{name: "read error", body: io.MultiReader(strings.NewReader("pay"), errReader{errBroken}), read: -1, skip: true},
+		{name: "left unread", body: strings.NewReader("payload"), read: 3, skip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent *string
+			skipped := 0
+			b := &mirrorBody{
+				ReadCloser: io.NopCloser(tt.body),
+				pending:    true,
+				send:       func(body []byte) { s := string(body); sent = &s },
+				skip:       func() { skipped++ },
+			}
+
+			var read []byte
+			if tt.read < 0 {
+				read, _ = io.ReadAll(b)
+			} else {
+				read = make([]byte, tt.read)
+				io.ReadFull(b, read)
+			}
+			b.finish()
+			b.finish()
+
+			if tt.read < 0 && !tt.skip && string(read) != tt.sent {
+				t.Errorf("primary read %d bytes, want the whole body", len(read))
+			}
+			if tt.skip {
+				if sent != nil || skipped != 1 {
+					t.Errorf("sent = %t, skipped %d times, want one skip", sent != nil, skipped)
+				}
+				return
+			}
+			if sent == nil || *sent != tt.sent || skipped != 0 {
+				t.Errorf("sent = %t, skipped %d times, want the body sent", sent != nil, skipped)
+			}
+		})
+	}
+}
+
+// errReader fails every read with err
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestMirrorStreamsPrimaryBody(t *testing.T) {
+	firstHalf := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		half := make([]byte, 5)
+		if _, err := io.ReadFull(r.Body, half); err != nil {
+			t.Errorf("primary failed to read: %v", err)
+			return
+		}
+		close(firstHalf)
+		rest, _ := io.ReadAll(r.Body)
+		io.WriteString(w, string(half)+string(rest))
+	}))
+	t.Cleanup(primary.Close)
+
+	shadowed := make(chan string, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		shadowed <- string(body)
+	}))
+	t.Cleanup(shadow.Close)
+
+	gw := newTestGateway(t, []Route{{
+		Path:   "/upload",
+		Method: "POST",
+		Target: primary.URL,
+		Mirror: &MirrorConfig{Target: shadow.URL},
+	}})
+
+	// The client sends the second half only once the primary upstream got the first,
+	// which deadlocks if the gateway reads the body before proxying it
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("hello"))
+		select {
+		case <-firstHalf:
+		case <-time.After(5 * time.Second):
+			pw.CloseWithError(errors.New("primary never got the first half"))
+			return
+		}
+		pw.Write([]byte(" world"))
+		pw.Close()
+	}()
+
+	req, _ := http.NewRequest("POST", gw.URL+"/upload", pr)
+	status, body := send(t, gw.Client(), req)
+	if status != http.StatusOK || body != "hello world" {
+		t.Fatalf("primary answered %d %q", status, body)
+	}
+
+	select {
+	case got := <-shadowed:
+		if got != "hello world" {
+			t.Errorf("shadow got %q, want the whole body", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("shadow request never arrived")
+	}
+}
@@ -0,0 +1,150 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing mock responses.
+// For stubbing backends, set "mock" on routes in config/routes/ instead.
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// mockResponse answers a route with a fixed response instead of proxying it
+type mockResponse struct {
+	status  int
+	headers map[string]string
+	body    []byte
+	json    bool
+	latency time.Duration
+}
+
+// newMockResponse creates a mock response from the route configuration, reading bodyFile once
+func newMockResponse(config *MockConfig) (*mockResponse, error) {
+	m := &mockResponse{
+		status:  config.Status,
+		headers: make(map[string]string, len(config.Headers)+1),
+		latency: durationOr(config.Latency, 0),
+	}
+	if m.status == 0 {
+		m.status = http.StatusOK
+	}
+	if m.status < 100 || m.status > 599 {
+		return nil, fmt.Errorf("invalid mock status %d", m.status)
+	}
+	if m.latency < 0 {
+		return nil, fmt.Errorf("mock latency can't be negative")
+	}
+
+	contentType := ""
+	switch {
+	case len(config.Body) > 0 && config.BodyFile != "":
+		return nil, fmt.Errorf("mock body and bodyFile are mutually exclusive")
+
+	case config.BodyFile != "":
+		data, err := os.ReadFile(config.BodyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mock bodyFile: %w", err)
+		}
+		m.body = data
+		if contentType = mime.TypeByExtension(filepath.Ext(config.BodyFile)); contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+
+	case len(config.Body) > 0:
+		// A JSON string body is sent as text; any other JSON value is sent as JSON
+		var text string
+		if json.Unmarshal(config.Body, &text) == nil {
+			m.body = []byte(text)
+			contentType = "text/plain; charset=utf-8"
+		} else {
+			m.body = config.Body
+			contentType = "application/json"
+		}
+	}
+
+	for key, value := range config.Headers {
+		m.headers[http.CanonicalHeaderKey(key)] = value
+	}
+	if _, ok := m.headers["Content-Type"]; !ok && contentType != "" {
+		m.headers["Content-Type"] = contentType
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(m.headers["Content-Type"])
+	m.json = mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+
+	for _, text := range append([]string{string(m.body)}, mapValues(m.headers)...) {
+		for _, match := range valuePlaceholder.FindAllStringSubmatch(text, -1) {
+			switch match[1] {
+			case "header", "query", "param":
+			default:
+				return nil, fmt.Errorf("unknown placeholder %s in mock", match[0])
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// handle waits out the configured latency and writes the mock response
+func (m *mockResponse) handle(ctx framework.Context) error {
+	if m.latency > 0 {
+		timer := time.NewTimer(m.latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Request().Context().Done():
+			return nil
+		}
+	}
+
+	res := ctx.Response()
+	for key, value := range m.headers {
+		res.Header().Set(key, m.render(ctx, value, false))
+	}
+
+	body := m.body
+	if valuePlaceholder.Match(body) {
+		body = []byte(m.render(ctx, string(body), m.json))
+	}
+
+	if len(body) > 0 {
+		res.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	res.WriteHeader(m.status)
+	if ctx.Request().Method == http.MethodHead {
+		return nil
+	}
+	_, err := res.Write(body)
+	return err
+}
+
+// render fills placeholders, escaping values for use inside JSON strings when needed
+func (m *mockResponse) render(ctx framework.Context, text string, inJSON bool) string {
+	return valuePlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		match := valuePlaceholder.FindStringSubmatch(placeholder)
+		resolved, _ := requestValue(ctx, match[1], match[2])
+		value := formatJSON(resolved)
+		if inJSON {
+			quoted, _ := json.Marshal(value)
+			value = string(quoted[1 : len(quoted)-1])
+		}
+		return value
+	})
+}
+
+// mapValues returns the values of a string map
+func mapValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for _, value := range m {
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestMockRenderEscapesValues(t *testing.T) {
+	srv := newTestGateway(t, []Route{{
+		Path:   "/m/:name",
+		Method: http.MethodGet,
+		Mock: &MockConfig{
+			Status: http.StatusOK,
+			Body:   json.RawMessage(`{"name":"{param.name}","q":"{query.q}","ok":true}`),
+		},
+	}})
+
+	tests := []struct {
+		name  string
+		param string
+		query string
+	}{
+		{name: "plain", param: "x", query: "y"},
+		{name: "trailing quote", param: `x"`, query: `y"`},
+		{name: "leading quote", param: `"x`, query: `"y`},
+		{name: "only quotes", param: `""`, query: `"`},
+		{name: "backslash", param: `x\`, query: `a\"b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/m/"+url.PathEscape(tt.param)+"?q="+url.QueryEscape(tt.query), nil)
+			status, body := send(t, srv.Client(), req)
+			if status != http.StatusOK {
+				t.Fatalf("status = %d, body %s", status, body)
+			}
+
+			var got struct {
+				Name string `json:"name"`
+				Q    string `json:"q"`
+				OK   bool   `json:"ok"`
+			}
+			if err := json.Unmarshal([]byte(body), &got); err != nil {
+				t.Fatalf("body %s is not valid JSON: %v", body, err)
+			}
+			if got.Name != tt.param || got.Q != tt.query || !got.OK {
+				t.Errorf("body = %s, want name %q and q %q", body, tt.param, tt.query)
+			}
+		})
+	}
+}
@@ -0,0 +1,337 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing request proxying logic.
+// For adding routes, edit JSON files in config/routes/ instead.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+	"github.com/alramdein/kaimon/pkg/middleware"
+)
+
+// Supported route protocols
+const (
+	ProtocolHTTP      = "http"
+	ProtocolWebSocket = "websocket"
+	ProtocolGRPC      = "grpc"
+)
+
+// proxyError is an error the gateway answers itself instead of proxying
+type proxyError struct {
+	status  int
+	message string
+}
+
+func (e *proxyError) Error() string {
+	return fmt.Sprintf("%d: %s", e.status, e.message)
+}
+
+var (
+	errBodyTooLarge     = &proxyError{http.StatusRequestEntityTooLarge, "request body too large"}
+	errNoHealthyTarget  = &proxyError{http.StatusServiceUnavailable, "no healthy upstream available"}
+	errCircuitOpen      = &proxyError{http.StatusServiceUnavailable, "upstream circuit open"}
+	errInvalidTarget    = &proxyError{http.StatusInternalServerError, "invalid target URL"}
+	errInvalidTransport = &proxyError{http.StatusInternalServerError, "invalid upstream transport"}
+	errProxyFailed      = &proxyError{http.StatusBadGateway, "failed to proxy request"}
+	errGatewayTimeout   = &proxyError{http.StatusGatewayTimeout, "upstream timed out"}
+	errResponseTooLarge = &proxyError{http.StatusBadGateway, "upstream response too large to filter"}
+)
+
+// proxy forwards requests for a single route to its upstreams
+type proxy struct {
+	route      Route
+	upstreams  []*upstream
+	balancer   balancer
+	retry      *retryPolicy
+	forwarder  *forwarder
+	transcoder *transcoder
+	rewriter   *pathRewriter
+	transports *TransportRegistry
+}
+
+// handle proxies a request and streams the upstream response back
+func (p *proxy) handle(ctx framework.Context) error {
+	req := ctx.Request()
+
+	// Upgrade handshakes take over the connection instead of proxying one exchange
+	upgrade := upgradeType(req.Header)
+	if p.route.Protocol == ProtocolWebSocket && !strings.EqualFold(upgrade, ProtocolWebSocket) {
+		ctx.Response().Header().Set("Upgrade", ProtocolWebSocket)
+		return p.fail(ctx, errUpgradeRequired)
+	}
+	if upgrade != "" {
+		return p.handleUpgrade(ctx)
+	}
+
+	if p.transcoder != nil {
+		return p.handleTranscoded(ctx)
+	}
+
+	resp, done, err := p.exchange(ctx, req)
+	if err != nil {
+		return p.fail(ctx, err)
+	}
+	defer done()
+
+	if isStreaming(p.route, resp) {
+		ctx.Set(middleware.ContextKeyStream, true)
+	}
+
+	return p.writeResponse(ctx, resp)
+}
+
+// exchange sends req upstream, retrying on another target when the policy allows it.
+// On success the caller must call done once the response body has been consumed.
+func (p *proxy) exchange(ctx framework.Context, req *http.Request) (*http.Response, func(), error) {
+	// Reject oversized request bodies before they reach the upstream
+	if p.route.MaxBodyBytes > 0 && req.ContentLength > p.route.MaxBodyBytes {
+		return nil, nil, errBodyTooLarge
+	}
+
+	body, err := newRequestBody(req, ctx.Response(), p.route.MaxBodyBytes, p.retry)
+	if err != nil {
+		return nil, nil, err
+	}
+	attempts := p.retry.attempts(req.Method, body.replayable())
+
+	// Bound the whole exchange, retries included; the client's context is the parent
+	// so a client abort frees the upstream connection right away
+	exchangeCtx, stopTimeout, cancel := withStoppableTimeout(req.Context(), durationOr(p.route.Timeout, 0))
+
+	tried := make(map[*upstream]bool)
+	for attempt := 1; ; attempt++ {
+		selected, err := p.pick(ctx, tried)
+		if err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		tried[selected] = true
+
+		resp, done, err := p.roundTrip(exchangeCtx, ctx, req, selected, body)
+		last := attempt >= attempts || exchangeCtx.Err() != nil
+
+		if err != nil {
+			if last || !p.retry.retryableError(err) {
+				cancel()
+				return nil, nil, err
+			}
+		} else if !last && p.retry.retryableStatus(resp.StatusCode) {
+			drain(resp.Body)
+			done()
+		} else {
+			if isStreaming(p.route, resp) {
+				// Streams stay open for as long as the upstream keeps sending
+				stopTimeout()
+			}
+			return resp, func() {
+				done()
+				cancel()
+			}, nil
+		}
+
+		log.Printf("Retrying %s %s (attempt %d of %d)", req.Method, req.URL.Path, attempt+1, attempts)
+		if err := p.retry.wait(exchangeCtx, attempt); err != nil {
+			cancel()
+			return nil, nil, err
+		}
+	}
+}
+
+// pick selects an available upstream, preferring targets not yet tried for this request
+func (p *proxy) pick(ctx framework.Context, tried map[*upstream]bool) (*upstream, error) {
+	healthy := availableUpstreams(p.upstreams)
+	if len(healthy) == 0 {
+		return nil, errNoHealthyTarget
+	}
+
+	candidates := closedUpstreams(healthy)
+	if len(candidates) == 0 {
+		return nil, errCircuitOpen
+	}
+
+	untried := make([]*upstream, 0, len(candidates))
+	for _, u := range candidates {
+		if !tried[u] {
+			untried = append(untried, u)
+		}
+	}
+	if len(untried) > 0 {
+		candidates = untried
+	}
+
+	return p.balancer.pick(ctx, candidates), nil
+}
+
+// roundTrip sends one attempt to the selected upstream. On success the caller must
+// call done once the response body has been consumed.
+func (p *proxy) roundTrip(parent context.Context, ctx framework.Context, req *http.Request, selected *upstream, body *requestBody) (*http.Response, func(), error) {
+	// Render and parse target URL
+	targetURL, err := url.Parse(selected.target.Render(ctx))
+	if err != nil {
+		return nil, nil, errInvalidTarget
+	}
+	if p.rewriter != nil {
+		p.rewriter.apply(targetURL, req.URL)
+	}
+	if req.URL.RawQuery != "" {
+		if targetURL.RawQuery != "" {
+			targetURL.RawQuery += "&" + req.URL.RawQuery
+		} else {
+			targetURL.RawQuery = req.URL.RawQuery
+		}
+	}
+
+	// Targets with a templated host look up their client per request
+	client := selected.client
+	if client == nil {
+		if client, err = p.transports.Client(targetURL, p.route.upstreamProtocol(), p.route.Transport); err != nil {
+			return nil, nil, errInvalidTransport
+		}
+	}
+
+	attemptCtx, stopTimeout, cancel := withStoppableTimeout(parent, p.retry.perTryTimeout)
+
+	proxyReq, err := http.NewRequestWithContext(attemptCtx, req.Method, targetURL.String(), body.reader())
+	if err != nil {
+		cancel()
+		return nil, nil, errInvalidTarget
+	}
+
+	// Stream the request body as-is; unknown lengths are sent chunked
+	proxyReq.ContentLength = body.length
+	proxyReq.Trailer = req.Trailer
+
+	// Copy end-to-end headers and add forwarding headers
+	for key, values := range req.Header {
+		for _, value := range values {
+			proxyReq.Header.Add(key, value)
+		}
+	}
+	removeRequestHopHeaders(proxyReq.Header)
+	if upgrade := upgradeType(req.Header); upgrade != "" {
+		// Keep the handshake headers of protocol upgrades
+		proxyReq.Header.Set("Connection", "Upgrade")
+		proxyReq.Header.Set("Upgrade", upgrade)
+	}
+	p.forwarder.apply(proxyReq.Header, req)
+
+	// Add custom headers from route config
+	for key, value := range p.route.Headers {
+		proxyReq.Header.Set(key, value)
+	}
+
+	if p.route.PreserveHost {
+		proxyReq.Host = req.Host
+	}
+
+	// Admit the request through the circuit breaker and track it as in-flight
+	// until the response is streamed
+	if !selected.acquire() {
+		cancel()
+		return nil, nil, errCircuitOpen
+	}
+	atomic.AddInt64(&selected.active, 1)
+	done := func() {
+		atomic.AddInt64(&selected.active, -1)
+		cancel()
+	}
+
+	resp, err := client.Do(proxyReq)
+	if err != nil {
+		done()
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			selected.release()
+			return nil, nil, errBodyTooLarge
+		}
+
+		// A client that went away isn't the upstream's fault
+		if req.Context().Err() != nil {
+			selected.release()
+		} else {
+			selected.observe(false)
+		}
+		return nil, nil, err
+	}
+	selected.observe(resp.StatusCode < http.StatusInternalServerError)
+
+	if isStreaming(p.route, resp) {
+		stopTimeout()
+	}
+
+	return resp, done, nil
+}
+
+// writeResponse streams the upstream response to the client
+func (p *proxy) writeResponse(ctx framework.Context, resp *http.Response) error {
+	defer resp.Body.Close()
+
+	// Copy end-to-end response headers
+	removeHopHeaders(resp.Header)
+	res := ctx.Response()
+	for key, values := range resp.Header {
+		for _, value := range values {
+			res.Header().Add(key, value)
+		}
+	}
+	p.forwarder.applyResponse(res.Header(), resp)
+	announceTrailers(res.Header(), resp.Trailer)
+
+	// Stream response body
+	res.WriteHeader(resp.StatusCode)
+	if err := streamBody(res, resp.Body); err != nil {
+		// A response filter refused the body before anything was sent
+		if errors.Is(err, middleware.ErrBodyTooLarge) {
+			return err
+		}
+		// Headers are already sent, so abort the connection to signal a truncated body
+		log.Printf("Failed to stream response from %s: %v", resp.Request.URL.Host, err)
+		panic(http.ErrAbortHandler)
+	}
+	copyTrailers(res.Header(), resp.Trailer)
+
+	return nil
+}
+
+// fail answers the request with a JSON error
+func (p *proxy) fail(ctx framework.Context, err error) error {
+	var perr *proxyError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &perr):
+	case errors.As(err, &maxBytesErr):
+		perr = errBodyTooLarge
+	case ctx.Request().Context().Err() != nil:
+		// The client went away, nobody is left to answer
+		return nil
+	case classifyError(err) == RetryOnTimeout:
+		perr = errGatewayTimeout
+	default:
+		perr = errProxyFailed
+	}
+
+	if p.route.Protocol == ProtocolGRPC {
+		return failGRPC(ctx, perr)
+	}
+
+	return ctx.JSON(perr.status, map[string]string{
+		"error": perr.message,
+	})
+}
+
+// drain discards what is left of a response body so its connection can be reused
+func drain(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, streamBufferSize))
+	body.Close()
+}
@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+	"github.com/alramdein/kaimon/pkg/middleware"
+)
+
+// newBenchmarkGateway serves routes in-process
+func newBenchmarkGateway(b *testing.B, routes []Route) http.Handler {
+	b.Helper()
+
+	fw := framework.NewEchoFramework()
+	loader := NewLoader(fw.Router(), middleware.NewManager())
+	if err := loader.Load(&CompiledRoutes{Routes: routes}); err != nil {
+		b.Fatalf("failed to load routes: %v", err)
+	}
+	b.Cleanup(loader.Close)
+
+	return fw.(http.Handler)
+}
+
+// newBenchmarkProxy serves a single proxy to target on /bench. With keepAlive unset, its
+// client's transport is cloned with keep-alives disabled, so every request dials the
+// upstream and the handler is otherwise the same.
+func newBenchmarkProxy(b *testing.B, target string, keepAlive bool) http.Handler {
+	b.Helper()
+
+	fw := framework.NewEchoFramework()
+	loader := NewLoader(fw.Router(), middleware.NewManager())
+	b.Cleanup(loader.Close)
+
+	p, err := loader.newProxy(Route{Path: "/bench", Method: http.MethodGet, Target: target})
+	if err != nil {
+		b.Fatalf("newProxy: %v", err)
+	}
+	if !keepAlive {
+		client := *p.upstreams[0].client
+		transport := client.Transport.(*http.Transport).Clone()
+		transport.DisableKeepAlives = true
+		client.Transport = transport
+		p.upstreams[0].client = &client
+		b.Cleanup(transport.CloseIdleConnections)
+	}
+
+	fw.Router().Handle(http.MethodGet, "/bench", p.handle)
+	return fw.(http.Handler)
+}
+
+// benchmarkGateway sends GET requests for path through the gateway
+func benchmarkGateway(b *testing.B, gw http.Handler, path string, header http.Header) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			b.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// newBenchmarkUpstream answers every request with a 1KB body
+func newBenchmarkUpstream(b *testing.B) *httptest.Server {
+	b.Helper()
+
+	body := strings.Repeat("x", 1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	b.Cleanup(srv.Close)
+	return srv
+}
+
+// BenchmarkProxyPooled proxies over the shared client, reusing upstream connections
+func BenchmarkProxyPooled(b *testing.B) {
+	upstream := newBenchmarkUpstream(b)
+	benchmarkGateway(b, newBenchmarkProxy(b, upstream.URL+"/", true), "/bench", nil)
+}
+
+// BenchmarkProxyConnectionPerRequest proxies with the same handler but a new upstream
+// connection per request
+func BenchmarkProxyConnectionPerRequest(b *testing.B) {
+	upstream := newBenchmarkUpstream(b)
+	benchmarkGateway(b, newBenchmarkProxy(b, upstream.URL+"/", false), "/bench", nil)
+}
+
+// BenchmarkProxyTemplatedHost proxies to a target whose host comes from a header, so
+// its shared client is looked up per request
+func BenchmarkProxyTemplatedHost(b *testing.B) {
+	upstream := newBenchmarkUpstream(b)
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	gw := newBenchmarkGateway(b, []Route{{Path: "/bench", Method: http.MethodGet, Target: "http://{header.X-Upstream}/"}})
+	benchmarkGateway(b, gw, "/bench", http.Header{"X-Upstream": {host}})
+}
+
+func TestNewProxyResolvesStaticClients(t *testing.T) {
+	loader := NewLoader(nil, middleware.NewManager())
+	t.Cleanup(loader.Close)
+
+	p, err := loader.newProxy(Route{
+		Path:   "/users/:id",
+		Method: "GET",
+		Targets: []Target{
+			{URL: "http://users:8081/users/:id"},
+			{URL: "http://{header.X-Region}.users:8081/users/:id"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newProxy: %v", err)
+	}
+
+	if p.upstreams[0].client == nil {
+		t.Errorf("static host has no client resolved at load time")
+	}
+	if p.upstreams[1].client != nil {
+		t.Errorf("templated host has a client resolved at load time")
+	}
+}
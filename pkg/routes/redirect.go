@@ -0,0 +1,148 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing redirects.
+// For redirecting routes, set "redirect" on routes, or httpsRedirect and trailingSlash on domains, in config/routes/ instead.
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// Trailing slash policies of a domain
+const (
+	TrailingSlashStrip = "strip"
+	TrailingSlashAdd   = "add"
+)
+
+// redirect answers a route with a redirect instead of proxying it
+type redirect struct {
+	to     *TargetTemplate
+	status int
+}
+
+// newRedirect creates a redirect from the route configuration
+func newRedirect(route Route) (*redirect, error) {
+	config := route.Redirect
+	if config.To == "" {
+		return nil, fmt.Errorf("redirect requires to")
+	}
+
+	status := config.Status
+	switch status {
+	case 0:
+		status = http.StatusMovedPermanently
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil, fmt.Errorf("unsupported redirect status %d", config.Status)
+	}
+
+	to, err := ParseTargetTemplate(config.To)
+	if err != nil {
+		return nil, err
+	}
+	if err := to.Validate(route.Path); err != nil {
+		return nil, err
+	}
+	if len(to.Responses()) > 0 {
+		return nil, fmt.Errorf("redirect %q can't reference responses", config.To)
+	}
+	if name := wildcardParam(route.Path); name != "" {
+		to.BindWildcard(name)
+	}
+
+	return &redirect{to: to, status: status}, nil
+}
+
+// handle sends the client to the rendered location, keeping the query string
+func (r *redirect) handle(ctx framework.Context) error {
+	location := r.to.Render(ctx)
+	if query := ctx.Request().URL.RawQuery; query != "" {
+		if strings.Contains(location, "?") {
+			location += "&" + query
+		} else {
+			location += "?" + query
+		}
+	}
+
+	ctx.Response().Header().Set("Location", location)
+	ctx.Response().WriteHeader(r.status)
+	return nil
+}
+
+// permanentRedirectStatus keeps the method of non-GET requests across a permanent redirect
+func permanentRedirectStatus(method string) int {
+	if method == http.MethodGet || method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL over HTTPS. X-Forwarded-Proto
+// only counts when a trusted proxy sent it, since any client can set it.
+func redirectToHTTPS(next framework.HandlerFunc, f *forwarder) framework.HandlerFunc {
+	return func(ctx framework.Context) error {
+		req := ctx.Request()
+		if req.TLS != nil {
+			return next(ctx)
+		}
+		if f.isTrusted(remoteIP(req)) && strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https") {
+			return next(ctx)
+		}
+
+		ctx.Response().Header().Set("Location", "https://"+req.Host+req.URL.RequestURI())
+		ctx.Response().WriteHeader(permanentRedirectStatus(req.Method))
+		return nil
+	}
+}
+
+// canonicalPath applies a domain's trailing slash policy to a route path
+func canonicalPath(path, policy string) string {
+	if path == "" || path == "/" || wildcardParam(path) != "" || strings.HasSuffix(path, "*") {
+		return path
+	}
+
+	switch policy {
+	case TrailingSlashStrip:
+		return strings.TrimSuffix(path, "/")
+	case TrailingSlashAdd:
+		if !strings.HasSuffix(path, "/") {
+			return path + "/"
+		}
+	}
+	return path
+}
+
+// trailingSlashAlias returns a route redirecting the other spelling of a canonical
+// route path to it, if the domain normalizes trailing slashes
+func trailingSlashAlias(route Route, policy string) (Route, bool) {
+	if canonicalPath(route.Path, policy) != route.Path || route.Path == "" || route.Path == "/" ||
+		strings.HasSuffix(route.Path, "*") || wildcardParam(route.Path) != "" {
+		return Route{}, false
+	}
+
+	var alias string
+	switch policy {
+	case TrailingSlashStrip:
+		alias = route.Path + "/"
+	case TrailingSlashAdd:
+		alias = strings.TrimSuffix(route.Path, "/")
+	default:
+		return Route{}, false
+	}
+
+	return Route{
+		Path:   alias,
+		Method: route.Method,
+		Hosts:  route.Hosts,
+		Match:  route.Match,
+		Redirect: &RedirectConfig{
+			To:     route.Path,
+			Status: permanentRedirectStatus(route.Method),
+		},
+		HTTPSRedirect: route.HTTPSRedirect,
+	}, true
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHTTPSRedirectTrustsForwardedProtoFromProxiesOnly(t *testing.T) {
+	upstream := newNamedUpstream(t, "upstream")
+
+	tests := []struct {
+		name     string
+		trusted  []string
+		method   string
+		proto    string
+		status   int
+		location string
+	}{
+		{name: "plain request", method: "GET", status: http.StatusMovedPermanently, location: "https://app.example.com/orders?page=2"},
+		{name: "spoofed proto", method: "GET", proto: "https", status: http.StatusMovedPermanently, location: "https://app.example.com/orders?page=2"},
+		{name: "proto from another proxy", trusted: []string{"10.0.0.0/8"}, method: "GET", proto: "https", status: http.StatusMovedPermanently},
+		{name: "proto from a trusted proxy", trusted: []string{"127.0.0.1"}, method: "GET", proto: "https", status: http.StatusOK},
+		{name: "plain proto from a trusted proxy", trusted: []string{"127.0.0.1"}, method: "GET", proto: "http", status: http.StatusMovedPermanently},
+		{name: "post keeps its method", method: "POST", status: http.StatusPermanentRedirect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := newTestGateway(t, []Route{{
+				Path:          "/orders",
+				Method:        tt.method,
+				Target:        upstream.URL,
+				HTTPSRedirect: true,
+				Forwarding:    &ForwardingConfig{TrustedProxies: tt.trusted},
+			}})
+			client := gw.Client()
+			client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+
+			req, _ := http.NewRequest(tt.method, gw.URL+"/orders?page=2", nil)
+			req.Host = "app.example.com"
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.location != "" && resp.Header.Get("Location") != tt.location {
+				t.Errorf("Location = %q, want %q", resp.Header.Get("Location"), tt.location)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing upstream retry logic.
+// For configuring retries, edit the retry block in config/routes/ instead.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Retryable network error classes
+const (
+	RetryOnConnect = "connect"
+	RetryOnReset   = "reset"
+	RetryOnTimeout = "timeout"
+)
+
+// Default retry settings
+const (
+	defaultRetryMaxAttempts  = 3
+	defaultRetryMaxBodyBytes = 1 << 20
+	defaultBackoffInitial    = 100 * time.Millisecond
+	defaultBackoffMax        = 2 * time.Second
+	defaultBackoffMultiplier = 2.0
+	defaultBackoffJitter     = 0.2
+)
+
+// defaultRetryStatuses are retried when retryOn isn't configured
+var defaultRetryStatuses = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// retryPolicy decides whether and when a failed upstream attempt is retried
+type retryPolicy struct {
+	maxAttempts   int
+	statuses      map[int]bool
+	errors        map[string]bool
+	initial       time.Duration
+	max           time.Duration
+	multiplier    float64
+	jitter        float64
+	perTryTimeout time.Duration
+	nonIdempotent bool
+	maxBodyBytes  int64
+}
+
+// newRetryPolicy builds a retry policy; a nil config never retries
+func newRetryPolicy(config *RetryConfig) (*retryPolicy, error) {
+	if config == nil {
+		return &retryPolicy{maxAttempts: 1}, nil
+	}
+
+	policy := &retryPolicy{
+		maxAttempts:   intOr(config.MaxAttempts, defaultRetryMaxAttempts),
+		statuses:      make(map[int]bool),
+		errors:        make(map[string]bool),
+		initial:       defaultBackoffInitial,
+		max:           defaultBackoffMax,
+		multiplier:    defaultBackoffMultiplier,
+		jitter:        defaultBackoffJitter,
+		perTryTimeout: durationOr(config.PerTryTimeout, 0),
+		nonIdempotent: config.RetryNonIdempotent,
+		maxBodyBytes:  config.MaxBodyBytes,
+	}
+	if policy.maxBodyBytes <= 0 {
+		policy.maxBodyBytes = defaultRetryMaxBodyBytes
+	}
+
+	statuses := config.RetryOn
+	if statuses == nil {
+		statuses = defaultRetryStatuses
+	}
+	for _, status := range statuses {
+		policy.statuses[status] = true
+	}
+
+	errorClasses := config.RetryOnErrors
+	if errorClasses == nil {
+		errorClasses = []string{RetryOnConnect, RetryOnReset, RetryOnTimeout}
+	}
+	for _, class := range errorClasses {
+		switch class {
+		case RetryOnConnect, RetryOnReset, RetryOnTimeout:
+			policy.errors[class] = true
+		default:
+			return nil, fmt.Errorf("unsupported retryOnErrors value: %q", class)
+		}
+	}
+
+	if backoff := config.Backoff; backoff != nil {
+		policy.initial = durationOr(backoff.Initial, defaultBackoffInitial)
+		policy.max = durationOr(backoff.Max, defaultBackoffMax)
+		if backoff.Multiplier > 0 {
+			policy.multiplier = backoff.Multiplier
+		}
+		if backoff.Jitter != nil {
+			policy.jitter = *backoff.Jitter
+		}
+		if policy.jitter < 0 || policy.jitter > 1 {
+			return nil, fmt.Errorf("backoff jitter must be between 0 and 1")
+		}
+	}
+
+	return policy, nil
+}
+
+// attempts returns how many attempts a request may take
+func (p *retryPolicy) attempts(method string, replayable bool) int {
+	if p.maxAttempts <= 1 || !replayable {
+		return 1
+	}
+	if !isIdempotent(method) && !p.nonIdempotent {
+		return 1
+	}
+	return p.maxAttempts
+}
+
+// retryableStatus reports whether an upstream status should be retried
+func (p *retryPolicy) retryableStatus(status int) bool {
+	return p.statuses[status]
+}
+
+// retryableError reports whether a transport error should be retried
+func (p *retryPolicy) retryableError(err error) bool {
+	class := classifyError(err)
+	return class != "" && p.errors[class]
+}
+
+// backoff returns the delay before the given retry: exponential, capped, then jittered
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	delay := float64(p.initial) * math.Pow(p.multiplier, float64(retry-1))
+	delay = math.Min(delay, float64(p.max))
+	delay += delay * p.jitter * (2*rand.Float64() - 1)
+	return time.Duration(delay)
+}
+
+// wait sleeps for the backoff before the given retry, returning early if ctx is done
+func (p *retryPolicy) wait(ctx context.Context, retry int) error {
+	timer := time.NewTimer(p.backoff(retry))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// isIdempotent reports whether repeating a method has no additional effect
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// classifyError maps a transport error to a retryable error class
+func classifyError(err error) string {
+	var netErr net.Error
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return RetryOnTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return RetryOnTimeout
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return RetryOnConnect
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return RetryOnConnect
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return RetryOnReset
+	default:
+		return ""
+	}
+}
+
+// requestBody supplies the incoming request body to one or more upstream attempts
+type requestBody struct {
+	stream   io.ReadCloser
+	buffered []byte
+	length   int64
+}
+
+// newRequestBody prepares the request body, buffering it for replay when the policy may retry
+func newRequestBody(req *http.Request, res http.ResponseWriter, maxBodyBytes int64, policy *retryPolicy) (*requestBody, error) {
+	if req.ContentLength == 0 {
+		return &requestBody{buffered: []byte{}}, nil
+	}
+
+	// Enforce the body limit while streaming bodies of unknown length
+	stream := req.Body
+	if maxBodyBytes > 0 {
+		stream = http.MaxBytesReader(res, req.Body, maxBodyBytes)
+	}
+
+	body := &requestBody{stream: stream, length: req.ContentLength}
+	if policy.attempts(req.Method, true) <= 1 {
+		return body, nil
+	}
+	if req.ContentLength > policy.maxBodyBytes {
+		return body, nil
+	}
+
+	// Read up to the replay cap; larger bodies are streamed once without retries
+	buf, err := io.ReadAll(io.LimitReader(stream, policy.maxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > policy.maxBodyBytes {
+		body.stream = readCloser{io.MultiReader(bytes.NewReader(buf), stream), stream}
+		return body, nil
+	}
+
+	return &requestBody{buffered: buf, length: int64(len(buf))}, nil
+}
+
+// replayable reports whether the body can be sent more than once
+func (b *requestBody) replayable() bool {
+	return b.buffered != nil
+}
+
+// reader returns the body for the next attempt
+func (b *requestBody) reader() io.ReadCloser {
+	if b.buffered == nil {
+		return b.stream
+	}
+	if len(b.buffered) == 0 {
+		return http.NoBody
+	}
+	return io.NopCloser(bytes.NewReader(b.buffered))
+}
+
+// readCloser pairs a reader with the closer of the underlying body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
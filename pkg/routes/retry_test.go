@@ -0,0 +1,209 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	ms := func(n int) *Duration {
+		d := Duration(time.Duration(n) * time.Millisecond)
+		return &d
+	}
+	jitter := func(j float64) *float64 { return &j }
+
+	tests := []struct {
+		name    string
+		backoff *BackoffConfig
+		retry   int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{name: "first retry", backoff: &BackoffConfig{Initial: ms(100), Jitter: jitter(0)}, retry: 1, min: 100 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "doubles", backoff: &BackoffConfig{Initial: ms(100), Jitter: jitter(0)}, retry: 3, min: 400 * time.Millisecond, max: 400 * time.Millisecond},
+		{name: "multiplier", backoff: &BackoffConfig{Initial: ms(10), Multiplier: 3, Jitter: jitter(0)}, retry: 3, min: 90 * time.Millisecond, max: 90 * time.Millisecond},
+		{name: "capped", backoff: &BackoffConfig{Initial: ms(100), Max: ms(250), Jitter: jitter(0)}, retry: 5, min: 250 * time.Millisecond, max: 250 * time.Millisecond},
+		{name: "default jitter", retry: 1, min: 80 * time.Millisecond, max: 120 * time.Millisecond},
+		{name: "jitter after cap", backoff: &BackoffConfig{Initial: ms(100), Max: ms(200), Jitter: jitter(0.5)}, retry: 4, min: 100 * time.Millisecond, max: 300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := newRetryPolicy(&RetryConfig{Backoff: tt.backoff})
+			if err != nil {
+				t.Fatalf("newRetryPolicy: %v", err)
+			}
+			for i := 0; i < 100; i++ {
+				if d := policy.backoff(tt.retry); d < tt.min || d > tt.max {
+					t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.retry, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     *RetryConfig
+		method     string
+		replayable bool
+		want       int
+	}{
+		{name: "no policy", method: "GET", replayable: true, want: 1},
+		{name: "idempotent", config: &RetryConfig{MaxAttempts: 3}, method: "PUT", replayable: true, want: 3},
+		{name: "default attempts", config: &RetryConfig{}, method: "GET", replayable: true, want: defaultRetryMaxAttempts},
+		{name: "non-idempotent", config: &RetryConfig{MaxAttempts: 3}, method: "POST", replayable: true, want: 1},
+		{name: "non-idempotent allowed", config: &RetryConfig{MaxAttempts: 3, RetryNonIdempotent: true}, method: "POST", replayable: true, want: 3},
+		{name: "body not replayable", config: &RetryConfig{MaxAttempts: 3}, method: "PUT", replayable: false, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := newRetryPolicy(tt.config)
+			if err != nil {
+				t.Fatalf("newRetryPolicy: %v", err)
+			}
+			if got := policy.attempts(tt.method, tt.replayable); got != tt.want {
+				t.Errorf("attempts = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "deadline", err: context.DeadlineExceeded, want: RetryOnTimeout},
+		{name: "wrapped deadline", err: fmt.Errorf("round trip: %w", context.DeadlineExceeded), want: RetryOnTimeout},
+		{name: "net timeout", err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}, want: RetryOnTimeout},
+		{name: "dial", err: &net.OpError{Op: "dial", Err: syscall.EHOSTUNREACH}, want: RetryOnConnect},
+		{name: "refused", err: fmt.Errorf("connect: %w", syscall.ECONNREFUSED), want: RetryOnConnect},
+		{name: "reset", err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}, want: RetryOnReset},
+		{name: "eof", err: io.ErrUnexpectedEOF, want: RetryOnReset},
+		{name: "other", err: fmt.Errorf("tls: bad certificate"), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingUpstream answers with status and records the bodies it received
+type recordingUpstream struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies []string
+}
+
+func newRecordingUpstream(t *testing.T, status int) *recordingUpstream {
+	t.Helper()
+
+	u := &recordingUpstream{}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		u.mu.Lock()
+		u.bodies = append(u.bodies, string(body))
+		u.mu.Unlock()
+
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%d:%s", status, body)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+func (u *recordingUpstream) received() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]string(nil), u.bodies...)
+}
+
+func TestProxyRetriesOnAnotherTarget(t *testing.T) {
+	initial := Duration(time.Millisecond)
+
+	tests := []struct {
+		name         string
+		method       string
+		chunked      bool
+		maxBodyBytes int64
+		// retried tells whether the failing target's 503 must be replaced by the healthy answer
+		retried bool
+	}{
+		{name: "idempotent with body", method: "PUT", retried: true},
+		{name: "body of unknown length", method: "PUT", chunked: true, retried: true},
+		{name: "without body", method: "GET", retried: true},
+		{name: "non-idempotent", method: "POST", retried: false},
+		{name: "body above the replay cap", method: "PUT", maxBodyBytes: 4, retried: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing := newRecordingUpstream(t, http.StatusServiceUnavailable)
+			healthy := newRecordingUpstream(t, http.StatusOK)
+			gw := newTestGateway(t, []Route{{
+				Path:    "/items",
+				Method:  tt.method,
+				Targets: []Target{{URL: failing.URL + "/items"}, {URL: healthy.URL + "/items"}},
+				Retry:   &RetryConfig{MaxAttempts: 2, MaxBodyBytes: tt.maxBodyBytes, Backoff: &BackoffConfig{Initial: &initial}},
+			}})
+
+			payload := ""
+			if tt.method != "GET" {
+				payload = "payload"
+			}
+
+			// Round robin starts on either target, so send enough requests to hit both first
+			for i := 0; i < 4; i++ {
+				var body io.Reader
+				if payload != "" {
+					body = strings.NewReader(payload)
+					if tt.chunked {
+						body = io.MultiReader(body)
+					}
+				}
+				req, _ := http.NewRequest(tt.method, gw.URL+"/items", body)
+
+				status, got := send(t, gw.Client(), req)
+				if tt.retried && (status != http.StatusOK || got != "200:"+payload) {
+					t.Fatalf("request %d: got %d %q, want the healthy target's answer", i, status, got)
+				}
+			}
+
+			failed := failing.received()
+			if len(failed) == 0 {
+				t.Fatalf("failing target was never tried")
+			}
+			for _, body := range append(failed, healthy.received()...) {
+				if body != payload {
+					t.Errorf("target received %q, want %q", body, payload)
+				}
+			}
+
+			served := len(healthy.received())
+			if tt.retried && served != 4 {
+				t.Errorf("healthy target served %d of 4 requests", served)
+			}
+			if !tt.retried && served+len(failed) != 4 {
+				t.Errorf("targets saw %d attempts for 4 requests, want no retries", served+len(failed))
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing upstream path rewriting.
+// For rewriting paths, set stripPrefix, addPrefix or rewrite on routes in config/routes/ instead.
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// pathRewriter maps the gateway request path onto the upstream path: strip, then regex, then add
+type pathRewriter struct {
+	stripPrefix string
+	match       *regexp.Regexp
+	replace     string
+	addPrefix   string
+}
+
+// rewritesPath reports whether the upstream path is derived from the request path
+func (r Route) rewritesPath() bool {
+	return r.StripPrefix != "" || r.AddPrefix != "" || r.Rewrite != nil
+}
+
+// inheritsRewrite reports whether the domain's path rewriting applies to the route.
+// Aggregated routes call full backend URLs, redirects and mocks have no upstream, and
+// targets with path placeholders build the upstream path themselves.
+func (r Route) inheritsRewrite() bool {
+	if len(r.Backends) > 0 || r.Redirect != nil || r.Mock != nil {
+		return false
+	}
+	for _, t := range routeTargets(r) {
+		tmpl, err := ParseTargetTemplate(t.URL)
+		if err == nil && (len(tmpl.Params()) > 0 || tmpl.HasWildcard()) {
+			return false
+		}
+	}
+	return true
+}
+
+// newPathRewriter creates a path rewriter from the route configuration
+func newPathRewriter(route Route) (*pathRewriter, error) {
+	rw := &pathRewriter{
+		stripPrefix: strings.TrimSuffix(route.StripPrefix, "/"),
+		addPrefix:   strings.TrimSuffix(route.AddPrefix, "/"),
+	}
+
+	for _, prefix := range []string{route.StripPrefix, route.AddPrefix} {
+		if prefix != "" && !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("path prefix %q must start with /", prefix)
+		}
+	}
+
+	if route.Rewrite != nil {
+		if route.Rewrite.Match == "" {
+			return nil, fmt.Errorf("rewrite requires match")
+		}
+		match, err := regexp.Compile(route.Rewrite.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite match: %w", err)
+		}
+		rw.match = match
+		rw.replace = route.Rewrite.Replace
+	}
+
+	return rw, nil
+}
+
+// rewrite maps an escaped request path onto the escaped upstream path
+func (rw *pathRewriter) rewrite(path string) string {
+	// Only whole segments are stripped, so /api doesn't strip /apix
+	if rw.stripPrefix != "" && (path == rw.stripPrefix || strings.HasPrefix(path, rw.stripPrefix+"/")) {
+		path = path[len(rw.stripPrefix):]
+	}
+
+	if rw.match != nil {
+		path = rw.match.ReplaceAllString(path, rw.replace)
+	}
+
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return rw.addPrefix + path
+}
+
+// apply appends the rewritten request path to the target's own path
+func (rw *pathRewriter) apply(target *url.URL, req *url.URL) {
+	escaped := strings.TrimSuffix(target.EscapedPath(), "/") + rw.rewrite(req.EscapedPath())
+
+	if unescaped, err := url.PathUnescape(escaped); err == nil {
+		target.Path = unescaped
+		target.RawPath = escaped
+	}
+}
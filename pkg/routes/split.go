@@ -0,0 +1,168 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing traffic splitting.
+// For canary rollouts, set "split" on routes in config/routes/ instead.
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+	"github.com/alramdein/kaimon/pkg/middleware"
+)
+
+// splitRoutes expands a split route into one single-target route per variant
+func splitRoutes(route Route) []Route {
+	routes := make([]Route, 0, len(route.Split))
+
+	for _, variant := range route.Split {
+		sub := route
+		sub.Target = variant.Target
+		sub.Targets = nil
+		sub.Split = nil
+		sub.Sticky = nil
+
+		// Variant headers override route headers
+		sub.Headers = make(map[string]string, len(route.Headers)+len(variant.Headers))
+		for key, value := range route.Headers {
+			sub.Headers[key] = value
+		}
+		for key, value := range variant.Headers {
+			sub.Headers[key] = value
+		}
+
+		routes = append(routes, sub)
+	}
+
+	return routes
+}
+
+// splitSelector picks the variant of a split route: a matching override first, then a
+// weighted rendezvous hash of the sticky key, then a weighted random draw
+type splitSelector struct {
+	names   []string
+	weights []int
+	total   int
+	// overrides holds, per variant, the request headers that force it
+	overrides []map[string]string
+	sticky    *consistentHashBalancer
+}
+
+// newSplitSelector creates a selector from the route's split and sticky configuration
+func newSplitSelector(route Route) (*splitSelector, error) {
+	s := &splitSelector{}
+	seen := make(map[string]bool)
+
+	for i, variant := range route.Split {
+		name := variant.Name
+		if name == "" {
+			name = fmt.Sprintf("variant-%d", i)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate split variant %q", name)
+		}
+		seen[name] = true
+
+		if variant.Weight < 0 {
+			return nil, fmt.Errorf("split variant %q has negative weight", name)
+		}
+
+		s.names = append(s.names, name)
+		s.weights = append(s.weights, variant.Weight)
+		s.overrides = append(s.overrides, variant.Override)
+		s.total += variant.Weight
+	}
+
+	if s.total == 0 {
+		return nil, fmt.Errorf("split needs a variant with positive weight")
+	}
+
+	if route.Sticky != nil {
+		switch route.Sticky.HashOn {
+		case HashOnHeader, HashOnCookie:
+			if route.Sticky.HashKey == "" {
+				return nil, fmt.Errorf("sticky split on %s requires hashKey", route.Sticky.HashOn)
+			}
+		case HashOnIP:
+		default:
+			return nil, fmt.Errorf("unsupported sticky hashOn: %q", route.Sticky.HashOn)
+		}
+		s.sticky = &consistentHashBalancer{hashOn: route.Sticky.HashOn, hashKey: route.Sticky.HashKey}
+	}
+
+	return s, nil
+}
+
+// pick returns the name of the variant serving the request
+func (s *splitSelector) pick(ctx framework.Context) string {
+	req := ctx.Request()
+	for i, override := range s.overrides {
+		if len(override) == 0 {
+			continue
+		}
+		matched := true
+		for header, value := range override {
+			if !matchValue(req.Header.Values(header), value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return s.names[i]
+		}
+	}
+
+	if s.sticky != nil {
+		if key := s.sticky.key(ctx); key != "" {
+			best, bestScore := len(s.names)-1, math.Inf(-1)
+			for i, weight := range s.weights {
+				if weight == 0 {
+					continue
+				}
+				if score := rendezvousScore(key, s.names[i], weight); score > bestScore {
+					best, bestScore = i, score
+				}
+			}
+			return s.names[best]
+		}
+	}
+
+	n := rand.IntN(s.total)
+	for i, weight := range s.weights {
+		if n < weight {
+			return s.names[i]
+		}
+		n -= weight
+	}
+	return s.names[len(s.names)-1]
+}
+
+// wrap records the chosen variant before the route middlewares run, so they can tag it,
+// unless it was already picked ahead of the global middlewares
+func (s *splitSelector) wrap(next framework.HandlerFunc) framework.HandlerFunc {
+	return func(ctx framework.Context) error {
+		if _, ok := ctx.Get(middleware.ContextKeyVariant).(string); !ok {
+			ctx.Set(middleware.ContextKeyVariant, s.pick(ctx))
+		}
+		return next(ctx)
+	}
+}
+
+// splitHandler runs the handler of the variant chosen for the request
+type splitHandler struct {
+	selector *splitSelector
+	handlers map[string]framework.HandlerFunc
+}
+
+// handle dispatches to the chosen variant, picking one if no selector ran yet
+func (h *splitHandler) handle(ctx framework.Context) error {
+	name, _ := ctx.Get(middleware.ContextKeyVariant).(string)
+	handler, ok := h.handlers[name]
+	if !ok {
+		name = h.selector.pick(ctx)
+		ctx.Set(middleware.ContextKeyVariant, name)
+		handler = h.handlers[name]
+	}
+	return handler(ctx)
+}
@@ -0,0 +1,147 @@
+package routes
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+	"github.com/alramdein/kaimon/pkg/middleware"
+)
+
+func TestSplitStickyWeightChange(t *testing.T) {
+	tests := []struct {
+		name   string
+		before []int
+		after  []int
+		// grown is the variant whose weight rises; keys may only move onto it
+		grown int
+	}{
+		{name: "raise canary", before: []int{90, 10}, after: []int{90, 20}, grown: 1},
+		{name: "raise first of three", before: []int{50, 30, 20}, after: []int{80, 30, 20}, grown: 0},
+		{name: "raise middle of three", before: []int{50, 30, 20}, after: []int{50, 60, 20}, grown: 1},
+		{name: "undrain variant", before: []int{100, 0}, after: []int{100, 5}, grown: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := newStickySelector(t, tt.before)
+			after := newStickySelector(t, tt.after)
+			grown := after.names[tt.grown]
+
+			moved := 0
+			for i := 0; i < 2000; i++ {
+				req := httptest.NewRequest("GET", "/", nil)
+				req.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+				ctx := newTestContext(req)
+
+				was, is := before.pick(ctx), after.pick(ctx)
+				if was == is {
+					continue
+				}
+				if is != grown {
+					t.Fatalf("key user-%d moved from %s to %s, want only moves onto %s", i, was, is, grown)
+				}
+				moved++
+			}
+			if moved == 0 {
+				t.Errorf("no key moved onto %s", grown)
+			}
+		})
+	}
+}
+
+func TestSplitStickyDistribution(t *testing.T) {
+	s := newStickySelector(t, []int{90, 10})
+
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-User", fmt.Sprintf("user-%d", i))
+		counts[s.pick(newTestContext(req))]++
+	}
+
+	if canary := counts["v1"]; canary < 800 || canary > 1200 {
+		t.Errorf("canary got %d of 10000 keys, want about 1000", canary)
+	}
+}
+
+func TestSplitOverride(t *testing.T) {
+	s, err := newSplitSelector(Route{Split: []SplitVariant{
+		{Name: "stable", Weight: 100},
+		{Name: "canary", Weight: 0, Override: map[string]string{"X-Canary": "1"}},
+	}})
+	if err != nil {
+		t.Fatalf("newSplitSelector: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	if got := s.pick(newTestContext(req)); got != "stable" {
+		t.Errorf("pick without override = %s, want stable", got)
+	}
+	req.Header.Set("X-Canary", "1")
+	if got := s.pick(newTestContext(req)); got != "canary" {
+		t.Errorf("pick with override = %s, want canary", got)
+	}
+}
+
+// newStickySelector creates a selector with variants v0, v1, ... keyed on X-User
+func newStickySelector(t *testing.T, weights []int) *splitSelector {
+	t.Helper()
+
+	route := Route{Sticky: &StickyConfig{HashOn: HashOnHeader, HashKey: "X-User"}}
+	for i, weight := range weights {
+		route.Split = append(route.Split, SplitVariant{Name: fmt.Sprintf("v%d", i), Weight: weight})
+	}
+	s, err := newSplitSelector(route)
+	if err != nil {
+		t.Fatalf("newSplitSelector: %v", err)
+	}
+	return s
+}
+
+// variantRecorder copies the variant it sees into a response header
+type variantRecorder struct{}
+
+func (variantRecorder) Name() string { return "variantRecorder" }
+
+func (variantRecorder) Handle(next framework.HandlerFunc) framework.HandlerFunc {
+	return func(ctx framework.Context) error {
+		variant, _ := ctx.Get(middleware.ContextKeyVariant).(string)
+		ctx.Response().Header().Set("X-Seen-Variant", variant)
+		return next(ctx)
+	}
+}
+
+func TestSplitVariantVisibleToGlobalMiddlewares(t *testing.T) {
+	stable := newNamedUpstream(t, "stable")
+	canary := newNamedUpstream(t, "canary")
+
+	manager := middleware.NewManager()
+	manager.RegisterOnRequest(variantRecorder{})
+	gw := newCompiledTestGateway(t, manager, &CompiledRoutes{
+		Middlewares: &MiddlewareConfig{OnRequest: []string{"variantRecorder"}},
+		Routes: []Route{{
+			Path:   "/app",
+			Method: "GET",
+			Split: []SplitVariant{
+				{Name: "stable", Target: stable.URL, Weight: 50},
+				{Name: "canary", Target: canary.URL, Weight: 50},
+			},
+		}},
+	})
+
+	for i := 0; i < 20; i++ {
+		resp, err := gw.Client().Get(gw.URL + "/app")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if seen := resp.Header.Get("X-Seen-Variant"); seen != string(body) {
+			t.Fatalf("global middleware saw variant %q, request went to %q", seen, body)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing proxy body streaming.
+// For adding routes, edit JSON files in config/routes/ instead.
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamBufferSize is the chunk size used when streaming bodies
+const streamBufferSize = 32 * 1024
+
+// streamBuffers pools copy buffers across requests
+var streamBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, streamBufferSize)
+		return &buf
+	},
+}
+
+// streamBody copies src to dst, flushing after every write so clients see data as it arrives
+func streamBody(dst http.ResponseWriter, src io.Reader) error {
+	bufPtr := streamBuffers.Get().(*[]byte)
+	defer streamBuffers.Put(bufPtr)
+	buf := *bufPtr
+
+	controller := http.NewResponseController(dst)
+	for {
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return err
+			}
+			// Writers without flush support simply buffer
+			_ = controller.Flush()
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// isStreaming reports whether a response is a long-lived stream, such as Server-Sent
+// Events or a long-poll on a streaming route
+func isStreaming(route Route, resp *http.Response) bool {
+	if route.Stream {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// withStoppableTimeout bounds ctx by d, if positive, until stop is called. Streamed responses
+// stop it once headers arrive, so the timeout covers the wait for headers but not the body.
+func withStoppableTimeout(parent context.Context, d time.Duration) (ctx context.Context, stop func() bool, cancel context.CancelFunc) {
+	ctx, cancelCause := context.WithCancelCause(parent)
+	if d <= 0 {
+		return ctx, func() bool { return false }, func() { cancelCause(context.Canceled) }
+	}
+
+	timer := time.AfterFunc(d, func() {
+		cancelCause(context.DeadlineExceeded)
+	})
+	return ctx, timer.Stop, func() {
+		timer.Stop()
+		cancelCause(context.Canceled)
+	}
+}
+
+// announceTrailers declares upstream trailer keys before the response header is written
+func announceTrailers(header http.Header, trailer http.Header) {
+	if len(trailer) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(trailer))
+	for key := range trailer {
+		keys = append(keys, key)
+	}
+	header.Add("Trailer", strings.Join(keys, ", "))
+}
+
+// copyTrailers copies upstream trailers once the body has been fully read
+func copyTrailers(header http.Header, trailer http.Header) {
+	for key, values := range trailer {
+		for _, value := range values {
+			header.Add(http.TrailerPrefix+key, value)
+		}
+	}
+}
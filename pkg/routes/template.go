@@ -0,0 +1,314 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing target templating logic.
+// For adding routes, edit JSON files in config/routes/ instead.
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// partKind represents the kind of a target template part
+type partKind int
+
+const (
+	partLiteral partKind = iota
+	partParam
+	partWildcard
+	partQuery
+	partHeader
+	partResponse
+)
+
+// templatePart is a single literal or placeholder of a target template
+type templatePart struct {
+	kind    partKind
+	value   string
+	index   int
+	inQuery bool
+}
+
+// TargetTemplate is a parsed upstream target whose placeholders are filled per request.
+//
+// Supported placeholders:
+//   - :name       path parameter declared by the route path
+//   - *           wildcard segment declared by the route path
+//   - :name       the wildcard itself when the route path ends in :name*
+//   - {query.x}   query parameter x of the incoming request
+//   - {header.X}  header X of the incoming request
+//   - {respN.a.b} field a.b of the JSON response of step N in a sequence
+type TargetTemplate struct {
+	raw   string
+	parts []templatePart
+}
+
+// ParseTargetTemplate parses a target URL template
+func ParseTargetTemplate(target string) (*TargetTemplate, error) {
+	tmpl := &TargetTemplate{raw: target}
+
+	// Path placeholders are only recognized after scheme://host
+	pathStart := 0
+	if i := strings.Index(target, "://"); i >= 0 {
+		pathStart = len(target)
+		if j := strings.IndexAny(target[i+3:], "/?"); j >= 0 {
+			pathStart = i + 3 + j
+		}
+	}
+
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			tmpl.parts = append(tmpl.parts, templatePart{kind: partLiteral, value: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	inQuery := false
+	for i := 0; i < len(target); i++ {
+		ch := target[i]
+		segmentStart := i >= pathStart && i > 0 && target[i-1] == '/' && !inQuery
+
+		switch {
+		case ch == '?' && i >= pathStart:
+			inQuery = true
+			literal.WriteByte(ch)
+
+		case ch == '{':
+			end := strings.IndexByte(target[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated placeholder in target %q", target)
+			}
+			token := target[i+1 : i+end]
+			part, err := parsePlaceholder(token)
+			if err != nil {
+				return nil, fmt.Errorf("invalid target %q: %w", target, err)
+			}
+			part.inQuery = inQuery
+			flush()
+			tmpl.parts = append(tmpl.parts, part)
+			i += end
+
+		case ch == ':' && segmentStart:
+			end := i + 1
+			for end < len(target) && isParamChar(target[end]) {
+				end++
+			}
+			if end == i+1 {
+				literal.WriteByte(ch)
+				continue
+			}
+			flush()
+			tmpl.parts = append(tmpl.parts, templatePart{kind: partParam, value: target[i+1 : end]})
+			i = end - 1
+
+		case ch == '*' && segmentStart:
+			flush()
+			tmpl.parts = append(tmpl.parts, templatePart{kind: partWildcard})
+
+		default:
+			literal.WriteByte(ch)
+		}
+	}
+	flush()
+
+	return tmpl, nil
+}
+
+// parsePlaceholder parses the inside of a {namespace.name} placeholder
+func parsePlaceholder(token string) (templatePart, error) {
+	namespace, name, ok := strings.Cut(token, ".")
+	if !ok || name == "" {
+		return templatePart{}, fmt.Errorf("malformed placeholder {%s}", token)
+	}
+
+	switch namespace {
+	case "query":
+		return templatePart{kind: partQuery, value: name}, nil
+	case "header":
+		return templatePart{kind: partHeader, value: name}, nil
+	}
+
+	if index, ok := strings.CutPrefix(namespace, "resp"); ok {
+		n, err := strconv.Atoi(index)
+		if err != nil || n < 0 {
+			return templatePart{}, fmt.Errorf("malformed placeholder {%s}", token)
+		}
+		return templatePart{kind: partResponse, value: name, index: n}, nil
+	}
+
+	return templatePart{}, fmt.Errorf("unknown placeholder {%s}", token)
+}
+
+// isParamChar reports whether c may appear in a path parameter name
+func isParamChar(c byte) bool {
+	return c == '_' || c == '-' ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9')
+}
+
+// String returns the raw template
+func (t *TargetTemplate) String() string {
+	return t.raw
+}
+
+// Params returns the path parameter names referenced by the template
+func (t *TargetTemplate) Params() []string {
+	params := make([]string, 0)
+	for _, part := range t.parts {
+		if part.kind == partParam {
+			params = append(params, part.value)
+		}
+	}
+	return params
+}
+
+// Responses returns the sequence steps whose responses the template references
+func (t *TargetTemplate) Responses() []int {
+	steps := make([]int, 0)
+	for _, part := range t.parts {
+		if part.kind == partResponse {
+			steps = append(steps, part.index)
+		}
+	}
+	return steps
+}
+
+// HasWildcard reports whether the template references the wildcard segment
+func (t *TargetTemplate) HasWildcard() bool {
+	for _, part := range t.parts {
+		if part.kind == partWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// BindWildcard renders the :name parameter as the wildcard, for route paths ending in :name*
+func (t *TargetTemplate) BindWildcard(name string) {
+	for i, part := range t.parts {
+		if part.kind == partParam && part.value == name {
+			t.parts[i] = templatePart{kind: partWildcard}
+		}
+	}
+}
+
+// Validate checks that every path placeholder is declared by the route path
+func (t *TargetTemplate) Validate(routePath string) error {
+	declared, wildcard := pathParams(routePath)
+
+	for _, name := range t.Params() {
+		if !declared[name] {
+			return fmt.Errorf("target %q references undeclared path parameter :%s", t.raw, name)
+		}
+	}
+
+	if t.HasWildcard() && !wildcard {
+		return fmt.Errorf("target %q references * but path %q has no wildcard", t.raw, routePath)
+	}
+
+	return nil
+}
+
+// Render fills the template placeholders from the request context
+func (t *TargetTemplate) Render(ctx framework.Context) string {
+	var sb strings.Builder
+
+	for _, part := range t.parts {
+		switch part.kind {
+		case partLiteral:
+			sb.WriteString(part.value)
+		case partParam:
+			sb.WriteString(escapePathSegment(ctx.Param(part.value)))
+		case partWildcard:
+			segments := strings.Split(ctx.Param("*"), "/")
+			for i, segment := range segments {
+				segments[i] = escapePathSegment(segment)
+			}
+			sb.WriteString(strings.Join(segments, "/"))
+		case partQuery:
+			sb.WriteString(escapeValue(ctx.QueryParam(part.value), part.inQuery))
+		case partHeader:
+			sb.WriteString(escapeValue(ctx.Request().Header.Get(part.value), part.inQuery))
+		case partResponse:
+			responses, _ := ctx.Get(responsesKey).([]interface{})
+			if part.index < len(responses) {
+				if value, ok := lookupJSON(responses[part.index], part.value); ok {
+					sb.WriteString(escapeValue(formatJSON(value), part.inQuery))
+				}
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// escapePathSegment escapes a path segment that may already be escaped
+func escapePathSegment(segment string) string {
+	if unescaped, err := url.PathUnescape(segment); err == nil {
+		segment = unescaped
+	}
+	return url.PathEscape(segment)
+}
+
+// escapeValue escapes a value for the path or the query string
+func escapeValue(value string, inQuery bool) string {
+	if inQuery {
+		return url.QueryEscape(value)
+	}
+	return url.PathEscape(value)
+}
+
+// pathParams returns the parameter names and wildcard declared by a route path
+func pathParams(path string) (map[string]bool, bool) {
+	declared := make(map[string]bool)
+	wildcard := false
+
+	for _, segment := range strings.Split(path, "/") {
+		switch {
+		case isNamedWildcard(segment):
+			declared[segment[1:len(segment)-1]] = true
+			wildcard = true
+		case strings.HasPrefix(segment, ":"):
+			declared[segment[1:]] = true
+		case segment == "*":
+			wildcard = true
+		}
+	}
+
+	return declared, wildcard
+}
+
+// isNamedWildcard reports whether a path segment is a :name* catch-all
+func isNamedWildcard(segment string) bool {
+	if len(segment) < 3 || segment[0] != ':' || !strings.HasSuffix(segment, "*") {
+		return false
+	}
+	for i := 1; i < len(segment)-1; i++ {
+		if !isParamChar(segment[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// wildcardParam returns the name of a route path's :name* catch-all, if any
+func wildcardParam(path string) string {
+	segments := strings.Split(path, "/")
+	if last := segments[len(segments)-1]; isNamedWildcard(last) {
+		return last[1 : len(last)-1]
+	}
+	return ""
+}
+
+// routerPath converts a route path to the router's syntax, where a catch-all is a bare *
+func routerPath(path string) string {
+	if name := wildcardParam(path); name != "" {
+		return strings.TrimSuffix(path, ":"+name+"*") + "*"
+	}
+	return path
+}
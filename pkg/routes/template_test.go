@@ -0,0 +1,208 @@
+package routes
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTargetTemplate(t *testing.T) {
+	tests := []struct {
+		target string
+		parts  []templatePart
+		err    string
+	}{
+		{
+			target: "http://users:8081/users",
+			parts:  []templatePart{{kind: partLiteral, value: "http://users:8081/users"}},
+		},
+		{
+			target: "http://users:8081/users/:id/orders",
+			parts: []templatePart{
+				{kind: partLiteral, value: "http://users:8081/users/"},
+				{kind: partParam, value: "id"},
+				{kind: partLiteral, value: "/orders"},
+			},
+		},
+		{
+			target: "http://files:8081/static/*",
+			parts: []templatePart{
+				{kind: partLiteral, value: "http://files:8081/static/"},
+				{kind: partWildcard},
+			},
+		},
+		{
+			target: "http://search:8081/find/{header.X-Tenant}?q={query.q}",
+			parts: []templatePart{
+				{kind: partLiteral, value: "http://search:8081/find/"},
+				{kind: partHeader, value: "X-Tenant"},
+				{kind: partLiteral, value: "?q="},
+				{kind: partQuery, value: "q", inQuery: true},
+			},
+		},
+		{
+			target: "http://orders:8081/users/{resp0.user.id}",
+			parts: []templatePart{
+				{kind: partLiteral, value: "http://orders:8081/users/"},
+				{kind: partResponse, value: "user.id", index: 0},
+			},
+		},
+		{
+			target: "http://users:8081/a:b/:/c",
+			parts:  []templatePart{{kind: partLiteral, value: "http://users:8081/a:b/:/c"}},
+		},
+		{target: "http://users:8081/{query.q", err: "unterminated placeholder"},
+		{target: "http://users:8081/{cookie.session}", err: "unknown placeholder {cookie.session}"},
+		{target: "http://users:8081/{query}", err: "malformed placeholder {query}"},
+		{target: "http://users:8081/{respx.id}", err: "malformed placeholder {respx.id}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			tmpl, err := ParseTargetTemplate(tt.target)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTargetTemplate: %v", err)
+			}
+			if !reflect.DeepEqual(tmpl.parts, tt.parts) {
+				t.Errorf("parts = %+v, want %+v", tmpl.parts, tt.parts)
+			}
+		})
+	}
+}
+
+func TestTargetTemplateRender(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		url       string
+		params    map[string]string
+		header    map[string]string
+		responses []interface{}
+		want      string
+	}{
+		{
+			name:   "param",
+			target: "http://users/users/:id",
+			params: map[string]string{"id": "42"},
+			want:   "http://users/users/42",
+		},
+		{
+			name:   "param with slash is escaped",
+			target: "http://users/users/:id",
+			params: map[string]string{"id": "a/b"},
+			want:   "http://users/users/a%2Fb",
+		},
+		{
+			name:   "escaped param is not escaped twice",
+			target: "http://users/users/:id",
+			params: map[string]string{"id": "a%20b"},
+			want:   "http://users/users/a%20b",
+		},
+		{
+			name:   "wildcard keeps its slashes",
+			target: "http://files/static/*",
+			params: map[string]string{"*": "css/a b.css"},
+			want:   "http://files/static/css/a%20b.css",
+		},
+		{
+			name:   "query value in the query string",
+			target: "http://search/find?q={query.q}",
+			url:    "/?q=a%26b+c",
+			want:   "http://search/find?q=a%26b+c",
+		},
+		{
+			name:   "query value in the path",
+			target: "http://search/find/{query.q}",
+			url:    "/?q=a+b",
+			want:   "http://search/find/a%20b",
+		},
+		{
+			name:   "header value in the path",
+			target: "http://tenants/{header.X-Tenant}/users",
+			header: map[string]string{"X-Tenant": "acme/eu"},
+			want:   "http://tenants/acme%2Feu/users",
+		},
+		{
+			name:   "missing values render empty",
+			target: "http://search/find?q={query.q}&t={header.X-Tenant}",
+			want:   "http://search/find?q=&t=",
+		},
+		{
+			name:      "response field",
+			target:    "http://orders/users/{resp0.user.id}?name={resp0.user.name}",
+			responses: []interface{}{map[string]interface{}{"user": map[string]interface{}{"id": float64(7), "name": "Ann Lee"}}},
+			want:      "http://orders/users/7?name=Ann+Lee",
+		},
+		{
+			name:   "response of a missing step",
+			target: "http://orders/users/{resp1.user.id}",
+			want:   "http://orders/users/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := ParseTargetTemplate(tt.target)
+			if err != nil {
+				t.Fatalf("ParseTargetTemplate: %v", err)
+			}
+
+			url := tt.url
+			if url == "" {
+				url = "/"
+			}
+			ctx := newTestContext(httptest.NewRequest("GET", url, nil))
+			for name, value := range tt.params {
+				ctx.params[name] = value
+			}
+			for name, value := range tt.header {
+				ctx.req.Header.Set(name, value)
+			}
+			if tt.responses != nil {
+				ctx.Set(responsesKey, tt.responses)
+			}
+
+			if got := tmpl.Render(ctx); got != tt.want {
+				t.Errorf("Render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetTemplateValidate(t *testing.T) {
+	tests := []struct {
+		target string
+		path   string
+		err    string
+	}{
+		{target: "http://users/users/:id", path: "/users/:id"},
+		{target: "http://users/users/:id", path: "/users/:name", err: "undeclared path parameter :id"},
+		{target: "http://files/static/*", path: "/static/*"},
+		{target: "http://files/static/:rest", path: "/static/:rest*"},
+		{target: "http://files/static/*", path: "/static/:rest*"},
+		{target: "http://files/static/*", path: "/static/:file", err: "has no wildcard"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.target, func(t *testing.T) {
+			tmpl, err := ParseTargetTemplate(tt.target)
+			if err != nil {
+				t.Fatalf("ParseTargetTemplate: %v", err)
+			}
+			err = tmpl.Validate(tt.path)
+			if tt.err == "" && err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
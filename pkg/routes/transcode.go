@@ -0,0 +1,361 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing gRPC-JSON transcoding.
+// For exposing gRPC methods as JSON routes, set "transcode" on routes in config/routes/ instead.
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxTranscodeMessageBytes caps upstream gRPC responses, matching gRPC's default limit
+const maxTranscodeMessageBytes = 4 << 20
+
+// transcoder maps JSON requests onto a unary gRPC method and the reply back to JSON
+type transcoder struct {
+	method protoreflect.MethodDescriptor
+	types  *dynamicpb.Types
+	body   string
+	params []string
+}
+
+// newTranscoder loads the route's gRPC method from a descriptor set
+// (protoc --include_imports --descriptor_set_out=...)
+func newTranscoder(route Route) (*transcoder, error) {
+	config := route.Transcode
+
+	data, err := os.ReadFile(config.DescriptorSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set %s: %w", config.DescriptorSet, err)
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load descriptor set %s: %w", config.DescriptorSet, err)
+	}
+
+	// Methods are named like the gRPC path: package.Service/Method
+	name := strings.TrimPrefix(config.Method, "/")
+	slash := strings.LastIndex(name, "/")
+	if slash < 0 {
+		return nil, fmt.Errorf("transcode method must look like package.Service/Method, got %q", config.Method)
+	}
+
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(name[:slash]))
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found in %s", name[:slash], config.DescriptorSet)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", name[:slash])
+	}
+	md := service.Methods().ByName(protoreflect.Name(name[slash+1:]))
+	if md == nil {
+		return nil, fmt.Errorf("method %s not found in service %s", name[slash+1:], service.FullName())
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("method %s is streaming; only unary methods can be transcoded", md.FullName())
+	}
+
+	t := &transcoder{
+		method: md,
+		types:  dynamicpb.NewTypes(files),
+		body:   config.Body,
+	}
+
+	// Methods with a request body take it as the whole message unless told otherwise
+	method := strings.ToUpper(route.Method)
+	if t.body == "" && method != http.MethodGet && method != http.MethodDelete {
+		t.body = "*"
+	}
+	if t.body != "" && t.body != "*" {
+		if findField(md.Input(), t.body) == nil {
+			return nil, fmt.Errorf("body field %s not found in %s", t.body, md.Input().FullName())
+		}
+	}
+
+	// Every path parameter must name a request field
+	params, _ := pathParams(route.Path)
+	for param := range params {
+		if findField(md.Input(), param) == nil {
+			return nil, fmt.Errorf("path parameter %s has no field in %s", param, md.Input().FullName())
+		}
+		t.params = append(t.params, param)
+	}
+
+	return t, nil
+}
+
+// path returns the upstream gRPC path of the method
+func (t *transcoder) path() string {
+	return "/" + string(t.method.Parent().FullName()) + "/" + string(t.method.Name())
+}
+
+// request builds the gRPC request message from the body, then the query, then path parameters
+func (t *transcoder) request(ctx framework.Context, body []byte) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(t.method.Input())
+	unmarshal := protojson.UnmarshalOptions{Resolver: t.types}
+
+	if len(bytes.TrimSpace(body)) > 0 {
+		switch t.body {
+		case "":
+			return nil, fmt.Errorf("this route takes no request body")
+		case "*":
+			if err := unmarshal.Unmarshal(body, msg); err != nil {
+				return nil, err
+			}
+		default:
+			// Decode the body as the value of a single field
+			field := findField(t.method.Input(), t.body)
+			wrapped := append([]byte(`{"`+field.JSONName()+`":`), body...)
+			wrapped = append(wrapped, '}')
+			partial := dynamicpb.NewMessage(t.method.Input())
+			if err := unmarshal.Unmarshal(wrapped, partial); err != nil {
+				return nil, err
+			}
+			proto.Merge(msg, partial)
+		}
+	}
+
+	// Unknown query parameters are ignored so clients may add their own
+	for key, values := range ctx.Request().URL.Query() {
+		if resolveField(msg, key) == nil {
+			continue
+		}
+		if err := setField(msg, key, values); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, param := range t.params {
+		if err := setField(msg, param, []string{ctx.Param(param)}); err != nil {
+			return nil, err
+		}
+	}
+
+	return msg, nil
+}
+
+// handleTranscoded calls the upstream gRPC method with a message built from the JSON
+// request and answers with the reply as JSON
+func (p *proxy) handleTranscoded(ctx framework.Context) error {
+	req := ctx.Request()
+
+	bodyReader := req.Body
+	if p.route.MaxBodyBytes > 0 {
+		bodyReader = http.MaxBytesReader(ctx.Response(), req.Body, p.route.MaxBodyBytes)
+	}
+	body, err := io.ReadAll(bodyReader)
+	if err != nil {
+		return p.fail(ctx, err)
+	}
+
+	msg, err := p.transcoder.request(ctx, body)
+	if err != nil {
+		return p.fail(ctx, &proxyError{http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err)})
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return p.fail(ctx, &proxyError{http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err)})
+	}
+
+	// Length-prefixed, uncompressed gRPC message
+	frame := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+
+	grpcReq := req.Clone(req.Context())
+	grpcReq.Method = http.MethodPost
+	grpcReq.URL.RawQuery = ""
+	grpcReq.Body = io.NopCloser(bytes.NewReader(frame))
+	grpcReq.ContentLength = int64(len(frame))
+	grpcReq.Header.Del("Content-Length")
+	grpcReq.Header.Del("Accept-Encoding")
+	grpcReq.Header.Set("Content-Type", "application/grpc")
+	grpcReq.Header.Set("Te", "trailers")
+
+	resp, done, err := p.exchange(ctx, grpcReq)
+	if err != nil {
+		return p.fail(ctx, err)
+	}
+	defer done()
+
+	if resp.StatusCode != http.StatusOK {
+		drain(resp.Body)
+		return p.fail(ctx, errProxyFailed)
+	}
+
+	// Trailers are only populated once the body has been read to the end
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, maxTranscodeMessageBytes+5))
+	if err != nil {
+		return p.fail(ctx, err)
+	}
+
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		// Trailers-only responses carry the status in the headers
+		status = resp.Header.Get("Grpc-Status")
+		message = resp.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return p.fail(ctx, errProxyFailed)
+	}
+	if code != grpcOK {
+		if decoded, err := url.PathUnescape(message); err == nil {
+			message = decoded
+		}
+		return ctx.JSON(httpStatus(code), map[string]string{
+			"error": message,
+		})
+	}
+
+	if len(reply) < 5 || reply[0] != 0 || int(binary.BigEndian.Uint32(reply[1:5])) != len(reply)-5 {
+		// Compressed, truncated or multiple messages can't come from a plain unary call
+		return p.fail(ctx, errProxyFailed)
+	}
+
+	out := dynamicpb.NewMessage(p.transcoder.method.Output())
+	if err := proto.Unmarshal(reply[5:], out); err != nil {
+		return p.fail(ctx, errProxyFailed)
+	}
+	data, err := protojson.MarshalOptions{Resolver: p.transcoder.types}.Marshal(out)
+	if err != nil {
+		return p.fail(ctx, errProxyFailed)
+	}
+
+	p.forwarder.applyResponse(ctx.Response().Header(), resp)
+	return ctx.JSON(http.StatusOK, json.RawMessage(data))
+}
+
+// findField looks up a field by its proto or JSON name
+func findField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	fields := md.Fields()
+	if fd := fields.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return fields.ByJSONName(name)
+}
+
+// resolveField follows a dotted field path (a.b.c) through nested messages
+func resolveField(msg protoreflect.Message, path string) protoreflect.FieldDescriptor {
+	md := msg.Descriptor()
+	segments := strings.Split(path, ".")
+
+	for i, segment := range segments {
+		fd := findField(md, segment)
+		if fd == nil {
+			return nil
+		}
+		if i == len(segments)-1 {
+			return fd
+		}
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return nil
+		}
+		md = fd.Message()
+	}
+	return nil
+}
+
+// setField sets a dotted field path from string values, appending to repeated fields
+func setField(msg protoreflect.Message, path string, values []string) error {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		fd := findField(msg.Descriptor(), segment)
+		if fd == nil || fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("%s is not a message field", path)
+		}
+		msg = msg.Mutable(fd).Message()
+	}
+
+	fd := findField(msg.Descriptor(), segments[len(segments)-1])
+	if fd == nil {
+		return fmt.Errorf("unknown field %s", path)
+	}
+	if fd.IsMap() {
+		return fmt.Errorf("map field %s can't be set from the URL", path)
+	}
+
+	for _, value := range values {
+		v, err := parseScalar(fd, value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", path, err)
+		}
+		if fd.IsList() {
+			msg.Mutable(fd).List().Append(v)
+		} else {
+			msg.Set(fd, v)
+		}
+	}
+	return nil
+}
+
+// parseScalar converts a URL string to a value of the field's kind
+func parseScalar(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			b, err = base64.URLEncoding.DecodeString(value)
+		}
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, fmt.Errorf("unknown enum value %q", value)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	default:
+		return protoreflect.Value{}, fmt.Errorf("message fields can't be set from the URL")
+	}
+}
@@ -0,0 +1,294 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing request body transformation.
+// For reshaping request bodies, set the request block on routes in config/routes/ instead.
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+// Request body conversions
+const (
+	ConvertFormToJSON = "form-to-json"
+	ConvertJSONToForm = "json-to-form"
+)
+
+// defaultTransformMaxBodyBytes caps bodies read for transformation when the route sets no limit
+const defaultTransformMaxBodyBytes = 1 << 20
+
+// valuePlaceholder matches {namespace.name} placeholders in request set values
+var valuePlaceholder = regexp.MustCompile(`\{([a-z]+)\.([^{}]+)\}`)
+
+// requestTransformer reshapes JSON and form request bodies: rename, then drop, then set
+type requestTransformer struct {
+	rename       map[string]string
+	drop         []string
+	set          map[string]interface{}
+	convert      string
+	maxBodyBytes int64
+}
+
+// newRequestTransformer creates a request transformer from configuration
+func newRequestTransformer(config *RequestConfig, maxBodyBytes int64) (*requestTransformer, error) {
+	switch config.Convert {
+	case "", ConvertFormToJSON, ConvertJSONToForm:
+	default:
+		return nil, fmt.Errorf("unsupported request convert: %q", config.Convert)
+	}
+
+	for field, value := range config.Set {
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		for _, match := range valuePlaceholder.FindAllStringSubmatch(s, -1) {
+			switch match[1] {
+			case "header", "query", "param", "jwt":
+			default:
+				return nil, fmt.Errorf("unknown placeholder %s in request set %s", match[0], field)
+			}
+		}
+	}
+
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultTransformMaxBodyBytes
+	}
+
+	return &requestTransformer{
+		rename:       config.Rename,
+		drop:         config.Drop,
+		set:          config.Set,
+		convert:      config.Convert,
+		maxBodyBytes: maxBodyBytes,
+	}, nil
+}
+
+// wrap rewrites the request body before it reaches the proxy
+func (t *requestTransformer) wrap(next framework.HandlerFunc) framework.HandlerFunc {
+	return func(ctx framework.Context) error {
+		req := ctx.Request()
+
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+		isForm := mediaType == "application/x-www-form-urlencoded"
+		if !isJSON && !isForm {
+			return next(ctx)
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(ctx.Response(), req.Body, t.maxBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return ctx.JSON(errBodyTooLarge.status, map[string]string{"error": errBodyTooLarge.message})
+			}
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+		}
+
+		fields, err := decodeBody(body, isJSON)
+		if err != nil {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		if fields != nil {
+			t.apply(ctx, fields)
+
+			toForm := t.convert == ConvertJSONToForm || (isForm && t.convert != ConvertFormToJSON)
+			if toForm {
+				body = []byte(encodeForm(fields))
+				mediaType = "application/x-www-form-urlencoded"
+			} else {
+				body, _ = json.Marshal(fields)
+				mediaType = "application/json"
+			}
+		}
+
+		transformed := req.Clone(req.Context())
+		transformed.Body = io.NopCloser(bytes.NewReader(body))
+		transformed.ContentLength = int64(len(body))
+		transformed.Header.Del("Content-Length")
+		if fields != nil {
+			transformed.Header.Set("Content-Type", mediaType)
+		}
+
+		ctx.SetRequest(transformed)
+		defer ctx.SetRequest(req)
+		return next(ctx)
+	}
+}
+
+// apply renames, drops and sets fields
+func (t *requestTransformer) apply(ctx framework.Context, fields map[string]interface{}) {
+	for from, to := range t.rename {
+		if value, ok := lookupJSON(fields, from); ok {
+			denyField(fields, strings.Split(from, "."))
+			setPath(fields, to, value)
+		}
+	}
+
+	for _, path := range t.drop {
+		denyField(fields, strings.Split(path, "."))
+	}
+
+	var claims map[string]interface{}
+	for path, value := range t.set {
+		s, ok := value.(string)
+		if !ok {
+			setPath(fields, path, value)
+			continue
+		}
+
+		lookup := func(namespace, name string) (interface{}, bool) {
+			if namespace == "jwt" {
+				if claims == nil {
+					claims = jwtClaims(ctx.Request())
+				}
+				return lookupJSON(claims, name)
+			}
+			return requestValue(ctx, namespace, name)
+		}
+
+		// A value that is a single placeholder keeps its source type, e.g. numeric claims
+		if match := valuePlaceholder.FindStringSubmatch(s); match != nil && match[0] == s {
+			if resolved, ok := lookup(match[1], match[2]); ok {
+				setPath(fields, path, resolved)
+			}
+			continue
+		}
+
+		setPath(fields, path, valuePlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+			match := valuePlaceholder.FindStringSubmatch(placeholder)
+			resolved, _ := lookup(match[1], match[2])
+			return formatJSON(resolved)
+		}))
+	}
+}
+
+// requestValue resolves a {header.X}, {query.x} or {param.name} placeholder
+func requestValue(ctx framework.Context, namespace, name string) (interface{}, bool) {
+	req := ctx.Request()
+	switch namespace {
+	case "header":
+		return req.Header.Get(name), req.Header.Get(name) != ""
+	case "query":
+		return ctx.QueryParam(name), req.URL.Query().Has(name)
+	case "param":
+		return ctx.Param(name), ctx.Param(name) != ""
+	}
+	return nil, false
+}
+
+// decodeBody decodes a JSON object or form body into fields. Other JSON values, and
+// empty bodies, return nil fields and are forwarded unchanged.
+func decodeBody(body []byte, isJSON bool) (map[string]interface{}, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+
+	if isJSON {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("invalid JSON request body")
+		}
+		fields, _ := value.(map[string]interface{})
+		return fields, nil
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid form request body")
+	}
+	fields := make(map[string]interface{}, len(values))
+	for key, list := range values {
+		if len(list) == 1 {
+			fields[key] = list[0]
+			continue
+		}
+		items := make([]interface{}, len(list))
+		for i, item := range list {
+			items[i] = item
+		}
+		fields[key] = items
+	}
+	return fields, nil
+}
+
+// encodeForm encodes fields as a form, flattening nested objects into dotted keys
+func encodeForm(fields map[string]interface{}) string {
+	values := url.Values{}
+
+	var flatten func(prefix string, value interface{})
+	flatten = func(prefix string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				flatten(key, child)
+			}
+		case []interface{}:
+			for _, item := range v {
+				flatten(prefix, item)
+			}
+		default:
+			values.Add(prefix, formatJSON(v))
+		}
+	}
+	flatten("", fields)
+
+	return values.Encode()
+}
+
+// setPath sets a dotted path, creating intermediate objects as needed
+func setPath(fields map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := fields[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			fields[key] = child
+		}
+		fields = child
+	}
+	fields[keys[len(keys)-1]] = value
+}
+
+// jwtClaims decodes the claims of the request's bearer token. The signature is not
+// verified here; an auth middleware must do that before the request reaches the route.
+func jwtClaims(req *http.Request) map[string]interface{} {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return map[string]interface{}{}
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return map[string]interface{}{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return map[string]interface{}{}
+	}
+
+	var claims map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&claims); err != nil || claims == nil {
+		return map[string]interface{}{}
+	}
+	return claims
+}
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestTransformerCapsBody(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(upstream.Close)
+
+	srv := newTestGateway(t, []Route{
+		{
+			Path:    "/default",
+			Method:  http.MethodPost,
+			Target:  upstream.URL,
+			Request: &RequestConfig{Drop: []string{"password"}},
+		},
+		{
+			Path:         "/limited",
+			Method:       http.MethodPost,
+			Target:       upstream.URL,
+			Request:      &RequestConfig{Drop: []string{"password"}},
+			MaxBodyBytes: 64,
+		},
+	})
+
+	large := `{"name":"` + strings.Repeat("x", defaultTransformMaxBodyBytes) + `"}`
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{name: "small body", path: "/default", body: `{"name":"a","password":"p"}`, status: http.StatusOK, want: `{"name":"a"}`},
+		{name: "over the default cap", path: "/default", body: large, status: http.StatusRequestEntityTooLarge},
+		{name: "over the route limit", path: "/limited", body: `{"name":"` + strings.Repeat("x", 64) + `"}`, status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, srv.URL+tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			status, body := send(t, srv.Client(), req)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d: %s", status, tt.status, body)
+			}
+			if tt.want != "" && body != tt.want {
+				t.Errorf("body = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
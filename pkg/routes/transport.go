@@ -0,0 +1,188 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing upstream connection logic.
+// For tuning upstream connections, edit the transport block in config/ JSON files instead.
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Default upstream transport settings
+const (
+	defaultDialTimeout         = 30 * time.Second
+	defaultKeepAlive           = 30 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultMaxIdleConnsPerHost = 64
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// TransportRegistry shares upstream HTTP clients so connections are reused across requests
+type TransportRegistry struct {
+	mu      sync.RWMutex
+	clients map[string]*http.Client
+}
+
+// NewTransportRegistry creates a new transport registry
+func NewTransportRegistry() *TransportRegistry {
+	return &TransportRegistry{
+		clients: make(map[string]*http.Client),
+	}
+}
+
+// Client returns the shared client for an upstream, route protocol and transport configuration
+func (r *TransportRegistry) Client(target *url.URL, protocol string, config *TransportConfig) (*http.Client, error) {
+	fingerprint, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint transport config: %w", err)
+	}
+	key := target.Scheme + "://" + target.Host + "|" + protocol + "|" + string(fingerprint)
+
+	r.mu.RLock()
+	client, exists := r.clients[key]
+	r.mu.RUnlock()
+	if exists {
+		return client, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, exists := r.clients[key]; exists {
+		return client, nil
+	}
+
+	transport, err := newTransport(config, protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	// Upstream redirects are passed back to the client instead of being followed
+	client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	r.clients[key] = client
+
+	return client, nil
+}
+
+// Close closes idle connections of every registered transport
+func (r *TransportRegistry) Close() {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, client := range r.clients {
+		client.CloseIdleConnections()
+	}
+}
+
+// newTransport builds an HTTP transport from configuration for a route protocol
+func newTransport(config *TransportConfig, protocol string) (*http.Transport, error) {
+	if config == nil {
+		config = &TransportConfig{}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   durationOr(config.DialTimeout, defaultDialTimeout),
+		KeepAlive: defaultKeepAlive,
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   durationOr(config.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: durationOr(config.ResponseHeaderTimeout, 0),
+		MaxIdleConnsPerHost:   defaultMaxIdleConnsPerHost,
+		IdleConnTimeout:       durationOr(config.IdleConnTimeout, defaultIdleConnTimeout),
+		ForceAttemptHTTP2:     true,
+		Proxy:                 http.ProxyFromEnvironment,
+	}
+
+	if config.MaxIdleConnsPerHost != nil {
+		transport.MaxIdleConnsPerHost = *config.MaxIdleConnsPerHost
+	}
+
+	switch {
+	case protocol == ProtocolGRPC:
+		// gRPC requires HTTP/2; plain-text upstreams get h2c with prior knowledge
+		if config.HTTP2 != nil && !*config.HTTP2 {
+			return nil, fmt.Errorf("grpc routes require http2")
+		}
+		protocols := new(http.Protocols)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = protocols
+	case config.HTTP2 != nil && !*config.HTTP2:
+		// A non-nil empty TLSNextProto disables HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	switch config.Proxy {
+	case "", "environment":
+		// Keep ProxyFromEnvironment
+	case "none":
+		transport.Proxy = nil
+	default:
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", config.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return transport, nil
+}
+
+// mergeTransport fills unset transport fields from the parent levels, nearest first
+func mergeTransport(levels ...*TransportConfig) *TransportConfig {
+	var merged *TransportConfig
+
+	for _, level := range levels {
+		if level == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &TransportConfig{}
+		}
+		if merged.DialTimeout == nil {
+			merged.DialTimeout = level.DialTimeout
+		}
+		if merged.TLSHandshakeTimeout == nil {
+			merged.TLSHandshakeTimeout = level.TLSHandshakeTimeout
+		}
+		if merged.ResponseHeaderTimeout == nil {
+			merged.ResponseHeaderTimeout = level.ResponseHeaderTimeout
+		}
+		if merged.MaxIdleConnsPerHost == nil {
+			merged.MaxIdleConnsPerHost = level.MaxIdleConnsPerHost
+		}
+		if merged.IdleConnTimeout == nil {
+			merged.IdleConnTimeout = level.IdleConnTimeout
+		}
+		if merged.HTTP2 == nil {
+			merged.HTTP2 = level.HTTP2
+		}
+		if merged.Proxy == "" {
+			merged.Proxy = level.Proxy
+		}
+	}
+
+	return merged
+}
+
+// durationOr returns the configured duration or a fallback
+func durationOr(d *Duration, fallback time.Duration) time.Duration {
+	if d == nil {
+		return fallback
+	}
+	return time.Duration(*d)
+}
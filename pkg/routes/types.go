@@ -3,38 +3,299 @@ package routes
 // WARNING: This is a core package. Do NOT modify unless you're changing route configuration structure.
 // For adding routes, edit JSON files in config/routes/ instead.
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // MiddlewareConfig represents middleware configuration with phases
 type MiddlewareConfig struct {
 	OnRequest  []string `json:"onRequest,omitempty"`
 	OnResponse []string `json:"onResponse,omitempty"`
 }
 
-// Route represents a single route configuration
-type Route struct {
-	Path        string            `json:"path"`
-	Method      string            `json:"method"`
+// Target represents a weighted upstream target. An unset weight counts as 1 and a
+// weight of 0 drains the target.
+type Target struct {
+	URL    string `json:"url"`
+	Weight *int   `json:"weight,omitempty"`
+}
+
+// weight returns the target weight, 1 when unset
+func (t Target) weight() int {
+	if t.Weight == nil {
+		return 1
+	}
+	return *t.Weight
+}
+
+// LoadBalancingConfig represents how a target is selected among several
+type LoadBalancingConfig struct {
+	Strategy string `json:"strategy"`
+	HashOn   string `json:"hashOn,omitempty"`
+	HashKey  string `json:"hashKey,omitempty"`
+}
+
+// HealthCheckConfig represents active and passive upstream health checking
+type HealthCheckConfig struct {
+	Active  *ActiveHealthCheck  `json:"active,omitempty"`
+	Passive *PassiveHealthCheck `json:"passive,omitempty"`
+}
+
+// ActiveHealthCheck probes an HTTP path on every target at an interval
+type ActiveHealthCheck struct {
+	Path               string    `json:"path"`
+	Interval           *Duration `json:"interval,omitempty"`
+	Timeout            *Duration `json:"timeout,omitempty"`
+	HealthyThreshold   int       `json:"healthyThreshold,omitempty"`
+	UnhealthyThreshold int       `json:"unhealthyThreshold,omitempty"`
+}
+
+// PassiveHealthCheck ejects a target after consecutive 5xx responses or connection errors
+type PassiveHealthCheck struct {
+	MaxFailures   int       `json:"maxFailures,omitempty"`
+	EjectDuration *Duration `json:"ejectDuration,omitempty"`
+}
+
+// CircuitBreakerConfig represents per-upstream circuit breaking
+type CircuitBreakerConfig struct {
+	FailureRatio     float64   `json:"failureRatio,omitempty"`
+	MinRequests      int       `json:"minRequests,omitempty"`
+	Window           *Duration `json:"window,omitempty"`
+	OpenDuration     *Duration `json:"openDuration,omitempty"`
+	HalfOpenRequests int       `json:"halfOpenRequests,omitempty"`
+}
+
+// RetryConfig represents how failed upstream calls are retried
+type RetryConfig struct {
+	MaxAttempts        int            `json:"maxAttempts,omitempty"`
+	RetryOn            []int          `json:"retryOn,omitempty"`
+	RetryOnErrors      []string       `json:"retryOnErrors,omitempty"`
+	Backoff            *BackoffConfig `json:"backoff,omitempty"`
+	PerTryTimeout      *Duration      `json:"perTryTimeout,omitempty"`
+	RetryNonIdempotent bool           `json:"retryNonIdempotent,omitempty"`
+	MaxBodyBytes       int64          `json:"maxBodyBytes,omitempty"`
+}
+
+// BackoffConfig represents exponential backoff between retries
+type BackoffConfig struct {
+	Initial    *Duration `json:"initial,omitempty"`
+	Max        *Duration `json:"max,omitempty"`
+	Multiplier float64   `json:"multiplier,omitempty"`
+	Jitter     *float64  `json:"jitter,omitempty"`
+}
+
+// ForwardingConfig represents the forwarding headers sent upstream
+type ForwardingConfig struct {
+	XForwarded     *bool    `json:"xForwarded,omitempty"`
+	Forwarded      *bool    `json:"forwarded,omitempty"`
+	Via            string   `json:"via,omitempty"`
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+}
+
+// Backend represents one upstream call of an aggregated route
+type Backend struct {
+	Target  string            `json:"target"`
+	Method  string            `json:"method,omitempty"`
+	Group   string            `json:"group,omitempty"`
+	Discard bool              `json:"discard,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout *Duration         `json:"timeout,omitempty"`
+}
+
+// SplitVariant is one version of a route in a traffic split
+type SplitVariant struct {
+	Name        string            `json:"name,omitempty"`
 	Target      string            `json:"target"`
-	Middlewares *MiddlewareConfig `json:"middlewares,omitempty"`
+	Weight      int               `json:"weight"`
 	Headers     map[string]string `json:"headers,omitempty"`
+	Middlewares *MiddlewareConfig `json:"middlewares,omitempty"`
+	Override    map[string]string `json:"override,omitempty"`
+}
+
+// StickyConfig keeps a client on the same split variant by hashing a request key
+type StickyConfig struct {
+	HashOn  string `json:"hashOn"`
+	HashKey string `json:"hashKey,omitempty"`
+}
+
+// MirrorConfig duplicates a percentage of requests to a shadow upstream
+type MirrorConfig struct {
+	Target     string  `json:"target"`
+	Percentage float64 `json:"percentage,omitempty"`
+}
+
+// MockConfig answers a route with a fixed response instead of proxying it
+type MockConfig struct {
+	Status   int               `json:"status,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     json.RawMessage   `json:"body,omitempty"`
+	BodyFile string            `json:"bodyFile,omitempty"`
+	Latency  *Duration         `json:"latency,omitempty"`
+}
+
+// RedirectConfig answers a route with a redirect instead of proxying it
+type RedirectConfig struct {
+	To     string `json:"to"`
+	Status int    `json:"status,omitempty"`
+}
+
+// RequestConfig reshapes request bodies before they are forwarded
+type RequestConfig struct {
+	Rename  map[string]string      `json:"rename,omitempty"`
+	Drop    []string               `json:"drop,omitempty"`
+	Set     map[string]interface{} `json:"set,omitempty"`
+	Convert string                 `json:"convert,omitempty"`
+}
+
+// ResponseConfig reshapes JSON response bodies before they reach the client
+type ResponseConfig struct {
+	Target  string            `json:"target,omitempty"`
+	Allow   []string          `json:"allow,omitempty"`
+	Deny    []string          `json:"deny,omitempty"`
+	Mapping map[string]string `json:"mapping,omitempty"`
+	Group   string            `json:"group,omitempty"`
+}
+
+// RewriteConfig rewrites the upstream path with a regular expression
+type RewriteConfig struct {
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+}
+
+// MatchConfig restricts a route to requests carrying the given headers, query parameters
+// and cookies; a value of "*" only requires presence
+type MatchConfig struct {
+	Headers map[string]string `json:"headers,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Cookies map[string]string `json:"cookies,omitempty"`
+}
+
+// TranscodeConfig maps a JSON route onto a unary gRPC method
+type TranscodeConfig struct {
+	DescriptorSet string `json:"descriptorSet"`
+	Method        string `json:"method"`
+	Body          string `json:"body,omitempty"`
+}
+
+// AdminConfig represents the gateway's own status endpoints
+type AdminConfig struct {
+	Path string `json:"path"`
+}
+
+// Route represents a single route configuration
+type Route struct {
+	Path           string                `json:"path"`
+	Method         string                `json:"method,omitempty"`
+	Methods        []string              `json:"methods,omitempty"`
+	Hosts          []string              `json:"hosts,omitempty"`
+	Match          *MatchConfig          `json:"match,omitempty"`
+	Protocol       string                `json:"protocol,omitempty"`
+	Target         string                `json:"target,omitempty"`
+	Targets        []Target              `json:"targets,omitempty"`
+	LoadBalancing  *LoadBalancingConfig  `json:"loadBalancing,omitempty"`
+	HealthCheck    *HealthCheckConfig    `json:"healthCheck,omitempty"`
+	CircuitBreaker *CircuitBreakerConfig `json:"circuitBreaker,omitempty"`
+	Retry          *RetryConfig          `json:"retry,omitempty"`
+	Timeout        *Duration             `json:"timeout,omitempty"`
+	Forwarding     *ForwardingConfig     `json:"forwarding,omitempty"`
+	PreserveHost   bool                  `json:"preserveHost,omitempty"`
+	StripPrefix    string                `json:"stripPrefix,omitempty"`
+	AddPrefix      string                `json:"addPrefix,omitempty"`
+	Rewrite        *RewriteConfig        `json:"rewrite,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	Transcode      *TranscodeConfig      `json:"transcode,omitempty"`
+	Backends       []Backend             `json:"backends,omitempty"`
+	FailurePolicy  string                `json:"failurePolicy,omitempty"`
+	Sequence       bool                  `json:"sequence,omitempty"`
+	Split          []SplitVariant        `json:"split,omitempty"`
+	Sticky         *StickyConfig         `json:"sticky,omitempty"`
+	Mirror         *MirrorConfig         `json:"mirror,omitempty"`
+	Mock           *MockConfig           `json:"mock,omitempty"`
+	Redirect       *RedirectConfig       `json:"redirect,omitempty"`
+	HTTPSRedirect  bool                  `json:"httpsRedirect,omitempty"`
+	Request        *RequestConfig        `json:"request,omitempty"`
+	Response       *ResponseConfig       `json:"response,omitempty"`
+	Middlewares    *MiddlewareConfig     `json:"middlewares,omitempty"`
+	Headers        map[string]string     `json:"headers,omitempty"`
+	Transport      *TransportConfig      `json:"transport,omitempty"`
+
+	// MaxBodyBytes rejects request bodies larger than this many bytes with 413
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
 }
 
 // RouteConfig represents the configuration for a domain
 type RouteConfig struct {
-	Domain      string            `json:"domain"`
-	BasePath    string            `json:"basePath"`
-	Routes      []Route           `json:"routes"`
-	Middlewares *MiddlewareConfig `json:"middlewares,omitempty"`
-	Headers     map[string]string `json:"headers,omitempty"`
+	Domain         string                `json:"domain"`
+	BasePath       string                `json:"basePath"`
+	Hosts          []string              `json:"hosts,omitempty"`
+	Routes         []Route               `json:"routes"`
+	Middlewares    *MiddlewareConfig     `json:"middlewares,omitempty"`
+	Headers        map[string]string     `json:"headers,omitempty"`
+	Transport      *TransportConfig      `json:"transport,omitempty"`
+	LoadBalancing  *LoadBalancingConfig  `json:"loadBalancing,omitempty"`
+	HealthCheck    *HealthCheckConfig    `json:"healthCheck,omitempty"`
+	CircuitBreaker *CircuitBreakerConfig `json:"circuitBreaker,omitempty"`
+	Retry          *RetryConfig          `json:"retry,omitempty"`
+	Timeout        *Duration             `json:"timeout,omitempty"`
+	Forwarding     *ForwardingConfig     `json:"forwarding,omitempty"`
+	StripPrefix    string                `json:"stripPrefix,omitempty"`
+	AddPrefix      string                `json:"addPrefix,omitempty"`
+	Rewrite        *RewriteConfig        `json:"rewrite,omitempty"`
+	Passthrough    string                `json:"passthrough,omitempty"`
+	HTTPSRedirect  bool                  `json:"httpsRedirect,omitempty"`
+	TrailingSlash  string                `json:"trailingSlash,omitempty"`
 }
 
 // GlobalConfig represents global configuration for all routes
 type GlobalConfig struct {
 	Middlewares *MiddlewareConfig `json:"middlewares,omitempty"`
 	Headers     map[string]string `json:"headers,omitempty"`
+	Transport   *TransportConfig  `json:"transport,omitempty"`
+	Timeout     *Duration         `json:"timeout,omitempty"`
+	Forwarding  *ForwardingConfig `json:"forwarding,omitempty"`
+	Admin       *AdminConfig      `json:"admin,omitempty"`
 }
 
 // CompiledRoutes represents the compiled route configuration
 type CompiledRoutes struct {
 	Middlewares *MiddlewareConfig `json:"middlewares,omitempty"`
+	Admin       *AdminConfig      `json:"admin,omitempty"`
 	Routes      []Route           `json:"routes"`
 }
+
+// Duration is a time.Duration configured as a string such as "5s" or "250ms"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// TransportConfig represents upstream connection settings
+type TransportConfig struct {
+	DialTimeout           *Duration `json:"dialTimeout,omitempty"`
+	TLSHandshakeTimeout   *Duration `json:"tlsHandshakeTimeout,omitempty"`
+	ResponseHeaderTimeout *Duration `json:"responseHeaderTimeout,omitempty"`
+	MaxIdleConnsPerHost   *int      `json:"maxIdleConnsPerHost,omitempty"`
+	IdleConnTimeout       *Duration `json:"idleConnTimeout,omitempty"`
+	HTTP2                 *bool     `json:"http2,omitempty"`
+	Proxy                 string    `json:"proxy,omitempty"`
+}
@@ -0,0 +1,112 @@
+package routes
+
+// WARNING: This is a core package. Do NOT modify unless you're changing protocol upgrade proxying.
+// For proxying WebSockets, set "protocol" on routes in config/routes/ instead.
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/alramdein/kaimon/pkg/framework"
+)
+
+var (
+	errUpgradeRequired    = &proxyError{http.StatusUpgradeRequired, "websocket upgrade required"}
+	errUpgradeFailed      = &proxyError{http.StatusBadGateway, "upstream refused protocol upgrade"}
+	errUpgradeUnsupported = &proxyError{http.StatusInternalServerError, "connection upgrade not supported"}
+)
+
+// upgradeType returns the protocol a request or response switches to, if any
+func upgradeType(header http.Header) string {
+	for _, value := range header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return header.Get("Upgrade")
+			}
+		}
+	}
+	return ""
+}
+
+// handleUpgrade proxies an upgrade handshake, then tunnels bytes in both directions
+// until either side closes the connection
+func (p *proxy) handleUpgrade(ctx framework.Context) error {
+	req := ctx.Request()
+	protocol := upgradeType(req.Header)
+
+	selected, err := p.pick(ctx, nil)
+	if err != nil {
+		return p.fail(ctx, err)
+	}
+
+	// The route timeout bounds the handshake only, not the tunnel that follows
+	handshakeCtx, stop, cancel := withStoppableTimeout(req.Context(), durationOr(p.route.Timeout, 0))
+	defer cancel()
+
+	resp, done, err := p.roundTrip(handshakeCtx, ctx, req, selected, &requestBody{buffered: []byte{}})
+	if err != nil {
+		return p.fail(ctx, err)
+	}
+	defer done()
+
+	// The upstream answered without switching; pass its response through
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return p.writeResponse(ctx, resp)
+	}
+
+	stop()
+
+	backConn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok || !strings.EqualFold(upgradeType(resp.Header), protocol) {
+		resp.Body.Close()
+		return p.fail(ctx, errUpgradeFailed)
+	}
+	defer backConn.Close()
+
+	clientConn, brw, err := ctx.Hijack()
+	if err != nil {
+		return p.fail(ctx, errUpgradeUnsupported)
+	}
+	defer clientConn.Close()
+
+	// Send the upstream's 101 along with headers set by middlewares
+	header := ctx.Response().Header()
+	removeHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", protocol)
+	p.forwarder.applyResponse(header, resp)
+
+	resp.Header = header
+	resp.Body = nil
+	if err := resp.Write(brw); err != nil {
+		return nil
+	}
+	if err := brw.Flush(); err != nil {
+		return nil
+	}
+
+	// Bytes the client sent after its handshake may already sit in brw's buffer
+	errc := make(chan error, 2)
+	go tunnel(backConn, brw, errc)
+	go tunnel(clientConn, backConn, errc)
+
+	// Once one direction ends, closing both connections ends the other
+	<-errc
+	clientConn.Close()
+	backConn.Close()
+	<-errc
+
+	return nil
+}
+
+// tunnel copies bytes from src to dst until either fails
+func tunnel(dst io.Writer, src io.Reader, errc chan<- error) {
+	_, err := io.Copy(dst, src)
+	errc <- err
+}